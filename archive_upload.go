@@ -0,0 +1,419 @@
+// Package godav - Archive uploads
+//
+// This file implements UploadArchive, which unpacks a tar, tar.gz or zip
+// stream into a remote directory. Entries are streamed straight into the
+// chunked uploader without being extracted to local disk, so an archive
+// can be uploaded from a network connection or another process.
+//
+// Features:
+//   - tar, gzip-compressed tar and zip archives
+//   - Directory structure recreated remotely, file modification times preserved
+//   - Hidden, symlink and special file policies shared with UploadDir
+//   - Entries with unsafe paths (e.g. "../") reported as failures
+//   - Aggregate progress and a DirUploadResult like UploadDir
+package godav
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// errStopArchive stops the archive walk after a failure under DirFailFast.
+var errStopArchive = errors.New("stop archive upload")
+
+// archiveEntry is a single entry read from an archive.
+type archiveEntry struct {
+	name       string      // Entry name as stored in the archive
+	mode       os.FileMode // File mode including type bits
+	size       int64       // Uncompressed size in bytes
+	modTime    time.Time   // Modification time recorded in the archive
+	linkTarget string      // Link target for symbolic and hard links
+	hardLink   bool        // Whether linkTarget names a hard link
+	r          io.Reader   // Entry content (regular files only)
+}
+
+// UploadArchive unpacks a tar, tar.gz or zip stream into remoteDir.
+//
+// Each file is streamed into the chunked uploader as it is read, and its
+// modification time from the archive is set on the remote file. Directories
+// are created as they are encountered (parent directories of files are
+// created implicitly). SkipExisting, SkipHidden, SymlinkPolicy and DirFailFast
+// apply as they do for UploadDir; symbolic links inside an archive cannot be
+// followed, so they are skipped unless SymlinkPolicy is SymlinkAsFile. Hard
+// links are regular files whose content is an earlier entry: they are copied
+// from that entry's remote file on the server.
+//
+// Zip archives need random access: readers that implement io.ReaderAt and
+// io.Seeker (such as *os.File) are read in place, anything else is spooled to
+// a temporary file first.
+//
+// Streamed entries cannot be resumed from a checkpoint, so CheckpointFunc and
+// ResumeFromCheckpoint are ignored. DirProgressFunc is called as for UploadDir,
+// except that file and byte totals grow as entries are read.
+//
+// Every entry is accounted for in the returned DirUploadResult, whose LocalDir
+// is empty. A non-nil error is returned when the archive cannot be read, the
+// context is cancelled, or any entry failed; the result is returned regardless.
+//
+// Example:
+//
+//	resp, err := http.Get("https://example.com/site.tar.gz")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer resp.Body.Close()
+//	result, err := client.UploadArchive(ctx, resp.Body, godav.ArchiveTarGz, "sites/example")
+func (c *Client) UploadArchive(ctx context.Context, r io.Reader, format ArchiveFormat, remoteDir string) (*DirUploadResult, error) {
+	c.config = c.validateConfig()
+	result := &DirUploadResult{
+		RemoteDir: remoteDir,
+		StartedAt: time.Now(),
+		archive:   true,
+	}
+	progress := newDirProgress("", nil, c.config.DirProgressFunc)
+	files := make(map[string]DirEntryResult) // Remote files by entry path, for hard links
+
+	walkErr := walkArchive(r, format, func(entry archiveEntry) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		res, skipped, ok := c.uploadArchiveEntry(ctx, entry, remoteDir, progress, files)
+		if !ok {
+			return nil
+		}
+		result.add(res, skipped)
+		if res.Err == nil && !res.IsDir && res.LinkTarget == "" && (!skipped || res.Reason == "already exists") {
+			files[res.RelPath] = res
+		}
+		if res.Err != nil {
+			if c.config.Verbose {
+				log.Printf("upload %s: %v", res.RemotePath, res.Err)
+			}
+			if c.config.DirFailFast {
+				return errStopArchive
+			}
+		}
+		return nil
+	})
+	result.Duration = time.Since(result.StartedAt)
+
+	err := result.Err()
+	if walkErr != nil && walkErr != errStopArchive {
+		err = walkErr
+	}
+	c.emitEvent(EventDirComplete, path.Base(remoteDir), remoteDir,
+		fmt.Sprintf("%d uploaded, %d skipped, %d failed", len(result.Uploaded), len(result.Skipped), len(result.Failed)),
+		err)
+	return result, err
+}
+
+// uploadArchiveEntry creates or uploads a single archive entry below
+// remoteDir. files holds the remote files of earlier entries, the targets of
+// hard links. ok is false for entries that map to remoteDir itself.
+func (c *Client) uploadArchiveEntry(ctx context.Context, entry archiveEntry, remoteDir string, progress *dirProgress, files map[string]DirEntryResult) (res DirEntryResult, skipped, ok bool) {
+	started := time.Now()
+	rel, err := cleanArchivePath(entry.name)
+	if err == nil && rel == "" {
+		return res, false, false
+	}
+
+	res = DirEntryResult{
+		RelPath:    rel,
+		RemotePath: c.pathJoin(remoteDir, rel),
+		IsDir:      entry.mode.IsDir(),
+		Size:       entry.size,
+	}
+	if err != nil {
+		res.RelPath = entry.name
+		res.Err = err
+		return res, false, true
+	}
+
+	if reason := c.archiveSkipReason(rel, entry); reason != "" {
+		res.Reason = reason
+		if c.config.Verbose {
+			log.Printf("Skip %s: %s", entry.name, reason)
+		}
+		c.emitEvent(EventUploadSkipped, path.Base(rel), res.RemotePath, "Skipped: "+reason, nil)
+		res.Duration = time.Since(started)
+		return res, true, true
+	}
+
+	switch {
+	case res.IsDir:
+		finalPath := c.toFilesPath(res.RemotePath)
+		if err := c.MkdirAll(finalPath, 0o755); err != nil && !c.isAlreadyExists(err) {
+			res.Err = fmt.Errorf("mkdir %s: %w", finalPath, err)
+		}
+	case entry.hardLink:
+		target, err := cleanArchivePath(entry.linkTarget)
+		src, found := files[target]
+		switch {
+		case err != nil:
+			res.Err = err
+		case !found:
+			res.Err = fmt.Errorf("hard link target %s was not uploaded", entry.linkTarget)
+		default:
+			res.Size = src.Size
+			progress.add(src.Size)
+			res.Err = c.copyArchiveHardLink(rel, src, res.RemotePath, progress)
+		}
+	case entry.linkTarget != "":
+		link := dirEntry{relPath: rel, linkTarget: entry.linkTarget, size: int64(len(linkDescription(entry.linkTarget)))}
+		res.LinkTarget = entry.linkTarget
+		res.RemotePath += symlinkSuffix
+		res.Size = link.size
+		progress.add(link.size)
+		res.Err = c.uploadLinkDescription(link, res.RemotePath, progress)
+	default:
+		progress.add(entry.size)
+		skipped, res.Err = c.uploadArchiveFile(ctx, rel, entry, res.RemotePath, progress)
+		if skipped {
+			res.Reason = "already exists"
+		}
+	}
+	res.Duration = time.Since(started)
+	return res, skipped, true
+}
+
+// archiveSkipReason applies the hidden, symlink and special file policies to
+// an archive entry, returning why it is excluded or "" to upload it.
+func (c *Client) archiveSkipReason(rel string, entry archiveEntry) string {
+	if c.config.SkipHidden {
+		for _, seg := range strings.Split(rel, "/") {
+			if strings.HasPrefix(seg, ".") {
+				return "hidden"
+			}
+		}
+	}
+
+	switch {
+	case entry.hardLink:
+		// Uploaded like the regular file it is
+		return ""
+	case entry.linkTarget != "":
+		switch c.config.SymlinkPolicy {
+		case SymlinkAsFile:
+			return ""
+		case SymlinkFollow:
+			return "symlink (cannot follow inside archive)"
+		default:
+			return "symlink"
+		}
+	case entry.mode.IsDir(), entry.mode.IsRegular():
+		return ""
+	default:
+		return specialFileReason(entry.mode)
+	}
+}
+
+// uploadArchiveFile streams a regular archive entry to remotePath, feeding
+// its chunk progress into the aggregate progress. It reports whether the file
+// was skipped.
+func (c *Client) uploadArchiveFile(ctx context.Context, rel string, entry archiveEntry, remotePath string, progress *dirProgress) (bool, error) {
+	cfg := *c.config
+	cfg.ResumeFromCheckpoint = nil
+	cfg.CheckpointFunc = nil
+	userProgress := cfg.ProgressFunc
+	cfg.ProgressFunc = func(info ProgressInfo) {
+		if userProgress != nil {
+			userProgress(info)
+		}
+		progress.update(rel, info.Current)
+	}
+
+	source := uploadSource{
+		localPath: rel,
+		r:         entry.r,
+		size:      entry.size,
+		modTime:   entry.modTime,
+	}
+
+	progress.update(rel, 0)
+	skipped, err := c.withConfig(&cfg).uploadSourceCore(ctx, source, remotePath)
+	progress.finish(rel, entry.size, skipped, err)
+	return skipped, err
+}
+
+// copyArchiveHardLink creates a hard-linked archive entry at remotePath by
+// copying the remote file of its target, an earlier entry of the archive.
+func (c *Client) copyArchiveHardLink(rel string, target DirEntryResult, remotePath string, progress *dirProgress) error {
+	progress.update(rel, 0)
+	finalPath := c.toFilesPath(remotePath)
+	if dir := c.dirOf(finalPath); dir != "" {
+		if err := c.MkdirAll(dir, 0o755); err != nil && !c.isAlreadyExists(err) {
+			progress.finish(rel, target.Size, false, err)
+			return fmt.Errorf("mkdir %s: %w", dir, err)
+		}
+	}
+	err := c.Copy(c.toFilesPath(target.RemotePath), finalPath, true)
+	if err != nil {
+		err = fmt.Errorf("copy %s: %w", target.RemotePath, err)
+	}
+	progress.finish(rel, target.Size, false, err)
+	return err
+}
+
+// cleanArchivePath converts an archive entry name to a clean relative path.
+// Names that would escape the destination directory are rejected.
+func cleanArchivePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("unsafe archive path %q", name)
+		}
+	}
+	cleaned := path.Clean("/" + name)
+	return strings.TrimPrefix(cleaned, "/"), nil
+}
+
+// walkArchive calls fn for every entry of the archive in r, in archive order.
+// It stops at the first error returned by fn.
+func walkArchive(r io.Reader, format ArchiveFormat, fn func(entry archiveEntry) error) error {
+	switch format {
+	case ArchiveTar:
+		return walkTar(r, fn)
+	case ArchiveTarGz:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("read gzip: %w", err)
+		}
+		defer zr.Close()
+		return walkTar(zr, fn)
+	case ArchiveZip:
+		return walkZip(r, fn)
+	default:
+		return fmt.Errorf("unsupported archive format %d", format)
+	}
+}
+
+func walkTar(r io.Reader, fn func(entry archiveEntry) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+
+		entry := archiveEntry{
+			name:    hdr.Name,
+			mode:    hdr.FileInfo().Mode(),
+			size:    hdr.Size,
+			modTime: hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeSymlink:
+			entry.linkTarget = hdr.Linkname
+			entry.size = 0
+		case tar.TypeLink:
+			entry.linkTarget = hdr.Linkname
+			entry.hardLink = true
+			entry.size = 0
+		default:
+			if entry.mode.IsRegular() {
+				entry.r = tr
+			}
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(r io.Reader, fn func(entry archiveEntry) error) error {
+	ra, size, cleanup, err := zipReaderAt(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("read zip: %w", err)
+	}
+
+	for _, f := range zr.File {
+		entry := archiveEntry{
+			name:    f.Name,
+			mode:    f.Mode(),
+			size:    int64(f.UncompressedSize64),
+			modTime: f.Modified,
+		}
+		if err := walkZipFile(f, entry, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkZipFile opens the content of a zip entry, if any, and passes it to fn.
+func walkZipFile(f *zip.File, entry archiveEntry, fn func(entry archiveEntry) error) error {
+	if entry.mode.IsRegular() || entry.mode&os.ModeSymlink != 0 {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open zip entry %s: %w", f.Name, err)
+		}
+		defer rc.Close()
+
+		if entry.mode&os.ModeSymlink != 0 {
+			// Zip stores the link target as the entry content
+			target, err := io.ReadAll(io.LimitReader(rc, 4096))
+			if err != nil {
+				return fmt.Errorf("read zip entry %s: %w", f.Name, err)
+			}
+			entry.linkTarget = string(target)
+			entry.size = 0
+		} else {
+			entry.r = rc
+		}
+	}
+	return fn(entry)
+}
+
+// zipReaderAt returns random access to a zip stream. Readers that support
+// ReadAt and Seek are used in place; anything else is spooled to a temporary
+// file that cleanup removes.
+func zipReaderAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	if ra, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		if size, err := ra.Seek(0, io.SeekEnd); err == nil {
+			return ra, size, func() {}, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "godav-archive-*.zip")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("spool zip: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("spool zip: %w", err)
+	}
+	return tmp, size, cleanup, nil
+}
@@ -6,7 +6,7 @@
 //
 // Features:
 //   - JSON-based checkpoint serialization
-//   - File-based checkpoint persistence
+//   - File-based checkpoint persistence (see checkpoint_store.go for stores)
 //   - Resume upload from saved checkpoints
 //   - Configuration restoration from checkpoints
 package godav
@@ -25,22 +25,28 @@ import (
 // Checkpoints are typically saved periodically during upload and can be
 // persisted to files, databases, or other storage systems for later resumption.
 type Checkpoint struct {
-	LocalPath      string    `json:"local_path"`      // Original local file path
-	RemotePath     string    `json:"remote_path"`     // Target remote path
-	UploadID       string    `json:"upload_id"`       // Unique upload session ID
-	FileSize       int64     `json:"file_size"`       // Total file size in bytes
-	ChunkSize      int64     `json:"chunk_size"`      // Size of each chunk
-	BytesUploaded  int64     `json:"bytes_uploaded"`  // Bytes successfully uploaded
-	ChunksUploaded int       `json:"chunks_uploaded"` // Number of chunks uploaded
-	TotalChunks    int       `json:"total_chunks"`    // Total number of chunks
-	Timestamp      time.Time `json:"timestamp"`       // When checkpoint was created
+	BaseURL        string    `json:"base_url,omitempty"` // WebDAV endpoint of the uploading client
+	Username       string    `json:"username,omitempty"` // User of the uploading client
+	LocalPath      string    `json:"local_path"`         // Original local file path
+	RemotePath     string    `json:"remote_path"`        // Target remote path
+	UploadID       string    `json:"upload_id"`          // Unique upload session ID
+	FileSize       int64     `json:"file_size"`          // Total file size in bytes
+	ModTime        time.Time `json:"mod_time"`           // Modification time of the local file
+	Dev            uint64    `json:"dev,omitempty"`      // Device number of the local file (0 where unavailable)
+	Inode          uint64    `json:"inode,omitempty"`    // Inode number of the local file (0 where unavailable)
+	ChunkSize      int64     `json:"chunk_size"`         // Size of each chunk
+	BytesUploaded  int64     `json:"bytes_uploaded"`     // Bytes successfully uploaded
+	ChunksUploaded int       `json:"chunks_uploaded"`    // Number of chunks uploaded
+	TotalChunks    int       `json:"total_chunks"`       // Total number of chunks
+	Timestamp      time.Time `json:"timestamp"`          // When checkpoint was created
 	// Essential config values (function pointers cannot be serialized)
 	ConfigChunkSize    int64 `json:"config_chunk_size"`    // Original chunk size setting
 	ConfigSkipExisting bool  `json:"config_skip_existing"` // Skip existing files setting
 	ConfigMaxRetries   int   `json:"config_max_retries"`   // Max retry attempts setting
 }
 
-// SaveCheckpoint saves a checkpoint to a file in JSON format.
+// SaveCheckpoint saves a checkpoint to a file in JSON format. The file is
+// replaced atomically and readable by the owner only.
 // The checkpoint can later be loaded and used to resume an interrupted upload.
 //
 // Parameters:
@@ -62,7 +68,7 @@ func SaveCheckpoint(checkpoint Checkpoint, filePath string) error {
 		return fmt.Errorf("marshal checkpoint: %w", err)
 	}
 
-	return os.WriteFile(filePath, data, 0644)
+	return writeFileAtomic(filePath, data, 0o600)
 }
 
 // LoadCheckpoint loads a checkpoint from a JSON file.
@@ -112,3 +118,15 @@ func (c *Client) ResumeUpload(checkpoint Checkpoint) error {
 
 	return c.UploadFile(checkpoint.LocalPath, checkpoint.RemotePath)
 }
+
+// matchesFile reports whether the checkpoint was taken of the current version
+// of a local file, uploaded with chunkSize. A file rewritten in place keeps
+// its size but not its modification time; resuming its upload would combine
+// old and new chunks.
+func (cp *Checkpoint) matchesFile(info os.FileInfo, chunkSize int64) bool {
+	if cp.ChunkSize != chunkSize || cp.FileSize != info.Size() || !cp.ModTime.Equal(info.ModTime()) {
+		return false
+	}
+	dev, inode := fileIdentity(info)
+	return cp.Inode == 0 || (cp.Dev == dev && cp.Inode == inode)
+}
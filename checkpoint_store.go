@@ -0,0 +1,202 @@
+// Package godav - Checkpoint stores
+//
+// This file persists upload checkpoints without CheckpointFunc plumbing.
+// With Config.CheckpointStore set, uploads save their checkpoints to the
+// store, resume from a stored checkpoint of the same local and remote path,
+// and delete it once the upload completed or was cancelled. Checkpoints are
+// keyed by server, user, local path and remote path, so clients of several
+// users can share a store and pending uploads can be listed and resumed
+// after a restart.
+//
+// Features:
+//   - Pluggable CheckpointStore interface
+//   - Directory store writing one JSON file per upload, atomically
+//   - In-memory store
+//   - Listing of all pending checkpoints
+package godav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CheckpointStore persists the checkpoints of unfinished uploads, one per
+// CheckpointKey. Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Save creates or replaces the checkpoint of cp.Key().
+	Save(cp Checkpoint) error
+	// Load returns the checkpoint of an upload, or nil if there is none.
+	Load(key CheckpointKey) (*Checkpoint, error)
+	// Delete removes the checkpoint of an upload. Deleting a missing checkpoint is not an error.
+	Delete(key CheckpointKey) error
+	// List returns all stored checkpoints, oldest first.
+	List() ([]Checkpoint, error)
+}
+
+// CheckpointKey identifies the upload a stored checkpoint belongs to. Remote
+// paths are compared after cleaning, so "Reports/q3.pdf" and
+// "files/<user>/Reports/q3.pdf" name the same upload.
+type CheckpointKey struct {
+	BaseURL    string // WebDAV endpoint of the client
+	Username   string // User of the client
+	LocalPath  string // Local file path
+	RemotePath string // Remote path
+}
+
+// Key returns the key the checkpoint is stored under.
+func (cp *Checkpoint) Key() CheckpointKey {
+	return CheckpointKey{BaseURL: cp.BaseURL, Username: cp.Username, LocalPath: cp.LocalPath, RemotePath: cp.RemotePath}
+}
+
+// clean returns the key with normalized paths, for comparisons.
+func (k CheckpointKey) clean() CheckpointKey {
+	k.BaseURL = strings.TrimSuffix(k.BaseURL, "/")
+	k.LocalPath = filepath.Clean(k.LocalPath)
+	k.RemotePath = cleanRemotePath(k.RemotePath)
+	return k
+}
+
+// sortCheckpoints orders checkpoints by their timestamp, oldest first.
+func sortCheckpoints(cps []Checkpoint) {
+	sort.Slice(cps, func(i, j int) bool { return cps[i].Timestamp.Before(cps[j].Timestamp) })
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps one JSON file per
+// upload in a directory. Files are written atomically, so a crash never
+// leaves a truncated checkpoint behind.
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a store in dir, creating the directory if
+// needed.
+//
+// Example:
+//
+//	store, err := godav.NewFileCheckpointStore("/var/lib/myapp/checkpoints")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cfg := godav.DefaultConfig()
+//	cfg.CheckpointStore = store
+//	client.SetConfig(cfg)
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create checkpoint store: %w", err)
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Save writes the checkpoint atomically.
+func (s *FileCheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	if err := writeFileAtomic(s.path(cp.Key()), data, 0o600); err != nil {
+		return fmt.Errorf("save checkpoint %s: %w", cp.LocalPath, err)
+	}
+	return nil
+}
+
+// Load reads the checkpoint file of an upload.
+func (s *FileCheckpointStore) Load(key CheckpointKey) (*Checkpoint, error) {
+	cp, err := LoadCheckpoint(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return cp, err
+}
+
+// Delete removes the checkpoint file of an upload.
+func (s *FileCheckpointStore) Delete(key CheckpointKey) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete checkpoint %s: %w", key.LocalPath, err)
+	}
+	return nil
+}
+
+// List reads every checkpoint in the directory, oldest first.
+func (s *FileCheckpointStore) List() ([]Checkpoint, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	cps := make([]Checkpoint, 0, len(matches))
+	for _, p := range matches {
+		cp, err := LoadCheckpoint(p)
+		if err != nil {
+			return nil, fmt.Errorf("list checkpoints: %w", err)
+		}
+		cps = append(cps, *cp)
+	}
+	sortCheckpoints(cps)
+	return cps, nil
+}
+
+func (s *FileCheckpointStore) path(key CheckpointKey) string {
+	// Paths may contain any character; name files by a hash of the key
+	key = key.clean()
+	sum := sha256.Sum256([]byte(strings.Join([]string{key.BaseURL, key.Username, key.LocalPath, key.RemotePath}, "\x00")))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// MemoryCheckpointStore is a CheckpointStore that keeps checkpoints in
+// memory, e.g. to resume uploads paused within one process or in tests.
+type MemoryCheckpointStore struct {
+	cps map[CheckpointKey]Checkpoint
+	mu  sync.Mutex
+}
+
+// NewMemoryCheckpointStore creates an empty in-memory store.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{cps: make(map[CheckpointKey]Checkpoint)}
+}
+
+// Save stores the checkpoint.
+func (s *MemoryCheckpointStore) Save(cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cps[cp.Key().clean()] = cp
+	return nil
+}
+
+// Load returns the checkpoint of an upload, or nil.
+func (s *MemoryCheckpointStore) Load(key CheckpointKey) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.cps[key.clean()]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// Delete removes the checkpoint of an upload.
+func (s *MemoryCheckpointStore) Delete(key CheckpointKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cps, key.clean())
+	return nil
+}
+
+// List returns all checkpoints, oldest first.
+func (s *MemoryCheckpointStore) List() ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cps := make([]Checkpoint, 0, len(s.cps))
+	for _, cp := range s.cps {
+		cps = append(cps, cp)
+	}
+	sortCheckpoints(cps)
+	return cps, nil
+}
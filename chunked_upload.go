@@ -11,21 +11,80 @@
 package godav
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
+
+	gowebdav "github.com/studio-b12/gowebdav"
 )
 
+// moveHeaders holds the headers added to the MOVE request that finalizes a
+// chunked upload. Finalization is serialized by Client.hdrMu.
+type moveHeaders struct {
+	h  http.Header
+	mu sync.Mutex
+}
+
+// set replaces the headers sent with MOVE requests.
+func (m *moveHeaders) set(h http.Header) {
+	m.mu.Lock()
+	m.h = h
+	m.mu.Unlock()
+}
+
+// intercept adds the pending headers to MOVE requests.
+func (m *moveHeaders) intercept(method string, rq *http.Request) {
+	if method != "MOVE" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, vals := range m.h {
+		rq.Header[k] = vals
+	}
+}
+
+// uploadSource is the data sent by uploadChunked: either a local file or a
+// stream of known length, such as an entry read from an archive.
+type uploadSource struct {
+	localPath string    // Local file path (display name only for streams)
+	r         io.Reader // Stream to upload instead of opening localPath
+	size      int64     // Stream length in bytes (local files are stat'ed)
+	modTime   time.Time // Modification time to set remotely (local files use their own)
+	checksum  string    // OC-Checksum value sent on finalization, e.g. "SHA1:<hex>" (optional)
+}
+
+// fileSource returns the upload source for a local file.
+func fileSource(localPath string) uploadSource {
+	return uploadSource{localPath: localPath}
+}
+
+// stat returns the number of bytes the source uploads and its modification time.
+func (s uploadSource) stat() (int64, time.Time, error) {
+	if s.r != nil {
+		return s.size, s.modTime, nil
+	}
+	info, err := os.Stat(s.localPath)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return info.Size(), info.ModTime(), nil
+}
+
 // uploadChunked performs the Nextcloud chunked upload protocol:
 // 1) MKCOL /uploads/<user>/<upload-id>
 // 2) PUT /uploads/<user>/<upload-id>/<offset> for each chunk
 // 3) MOVE /uploads/<user>/<upload-id>/.file -> /files/<user>/<dst>
-func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string) error {
+func (c *Client) uploadChunked(ctx context.Context, source uploadSource, finalPath string) error {
+	localPath := source.localPath
 	// Cache filename to avoid repeated path.Base calls
 	filename := filepath.Base(localPath)
 
@@ -36,12 +95,23 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 	var sent int64
 	var chunkIndex int
 
-	if c.config.ResumeFromCheckpoint != nil {
+	// Checkpoints of file uploads also go to the CheckpointStore; streams
+	// cannot be resumed
+	store := c.config.CheckpointStore
+	if source.r != nil {
+		store = nil
+	}
+	resume := c.config.ResumeFromCheckpoint
+	if resume == nil && store != nil {
+		resume = c.storedCheckpoint(store, localPath, finalPath)
+	}
+
+	if resume != nil {
 		// Resume from checkpoint
-		uploadID = c.config.ResumeFromCheckpoint.UploadID
+		uploadID = resume.UploadID
 		uploadBase = c.pathJoinMany("uploads", c.username, uploadID)
-		sent = c.config.ResumeFromCheckpoint.BytesUploaded
-		chunkIndex = c.config.ResumeFromCheckpoint.ChunksUploaded
+		sent = resume.BytesUploaded
+		chunkIndex = resume.ChunksUploaded
 		startOffset = int64(chunkIndex) * c.config.ChunkSize
 
 		c.emitEvent(EventUploadResumed, filename, finalPath,
@@ -63,25 +133,46 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 		}
 	}
 
-	// Open and get file info
-	f, err := os.Open(localPath)
-	if err != nil {
-		return fmt.Errorf("open %s: %w", localPath, err)
-	}
-	defer f.Close()
+	// Remove the uploaded chunks and the checkpoint if the upload is
+	// cancelled, however it stops
+	defer func() {
+		if c.config.Controller != nil && c.config.Controller.State() == StateCancelled {
+			_ = c.RemoveAll(uploadBase)
+			c.deleteCheckpoint(store, localPath, finalPath)
+		}
+	}()
 
-	fi, err := f.Stat()
-	if err != nil {
-		return fmt.Errorf("stat %s: %w", localPath, err)
-	}
-	total := fi.Size()
+	// Open the local file, or use the stream as is
+	r := source.r
+	total := source.size
+	modTime := source.modTime
+	var fileInfo os.FileInfo // Local file version recorded in checkpoints
+	if r == nil {
+		f, err := os.Open(localPath)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", localPath, err)
+		}
+		defer f.Close()
 
-	// Seek to resume position if needed
-	if startOffset > 0 {
-		_, err = f.Seek(startOffset, io.SeekStart)
+		fi, err := f.Stat()
 		if err != nil {
-			return fmt.Errorf("seek to offset %d: %w", startOffset, err)
+			return fmt.Errorf("stat %s: %w", localPath, err)
 		}
+		total = fi.Size()
+		fileInfo = fi
+		if modTime.IsZero() {
+			modTime = fi.ModTime()
+		}
+
+		// Seek to resume position if needed
+		if startOffset > 0 {
+			if _, err := f.Seek(startOffset, io.SeekStart); err != nil {
+				return fmt.Errorf("seek to offset %d: %w", startOffset, err)
+			}
+		}
+		r = f
+	} else if startOffset > 0 {
+		return fmt.Errorf("resume %s: streams cannot be resumed from a checkpoint", localPath)
 	}
 
 	// Validate chunk size
@@ -102,52 +193,90 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 
 	totalChunks := calculateChunks(total, chunkSize)
 
-	for offset := startOffset; offset < total; offset += chunkSize {
-		// Early cancellation check each iteration
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	// saveCheckpoint passes a checkpoint of the confirmed chunks to the
+	// CheckpointStore and CheckpointFunc
+	cpChunks, cpBytes, cpTime := chunkIndex, sent, time.Now() // At the last checkpoint
+	saveCheckpoint := func() {
+		if store == nil && c.config.CheckpointFunc == nil {
+			return
+		}
+		cpChunks, cpBytes, cpTime = chunkIndex, sent, time.Now()
+		checkpoint := Checkpoint{
+			BaseURL:            c.baseURL,
+			Username:           c.username,
+			LocalPath:          localPath,
+			RemotePath:         finalPath,
+			UploadID:           uploadID,
+			FileSize:           total,
+			ChunkSize:          chunkSize,
+			BytesUploaded:      sent,
+			ChunksUploaded:     chunkIndex,
+			TotalChunks:        totalChunks,
+			Timestamp:          time.Now(),
+			ConfigChunkSize:    c.config.ChunkSize,
+			ConfigSkipExisting: c.config.SkipExisting,
+			ConfigMaxRetries:   c.config.MaxRetries,
+		}
+		if fileInfo != nil {
+			checkpoint.ModTime = fileInfo.ModTime()
+			checkpoint.Dev, checkpoint.Inode = fileIdentity(fileInfo)
 		}
-		// Check for pause/resume/cancel
-		if c.config.Controller != nil {
-			switch c.config.Controller.State() {
+		if store != nil {
+			if err := store.Save(checkpoint); err != nil && c.config.Verbose {
+				log.Printf("checkpoint store: %v", err)
+			}
+		}
+		if c.config.CheckpointFunc != nil {
+			c.config.CheckpointFunc(checkpoint)
+		}
+	}
+
+	// Handle pause/resume/cancel: a paused upload saves a checkpoint of the
+	// confirmed chunks and waits for Resume, Cancel or ctx
+	ctrl := c.config.Controller
+	awaitRunning := func() error {
+		paused := false
+		for {
+			switch ctrl.State() {
 			case StatePaused:
+				paused = true
 				c.emitEvent(EventUploadPaused, filename, finalPath, "Upload paused", nil)
 
-				// Save checkpoint
-				if c.config.CheckpointFunc != nil {
-					checkpoint := Checkpoint{
-						LocalPath:          localPath,
-						RemotePath:         finalPath,
-						UploadID:           uploadID,
-						FileSize:           total,
-						ChunkSize:          chunkSize,
-						BytesUploaded:      sent,
-						ChunksUploaded:     chunkIndex,
-						TotalChunks:        totalChunks,
-						Timestamp:          time.Now(),
-						ConfigChunkSize:    c.config.ChunkSize,
-						ConfigSkipExisting: c.config.SkipExisting,
-						ConfigMaxRetries:   c.config.MaxRetries,
-					}
-					c.config.CheckpointFunc(checkpoint)
-				}
+				saveCheckpoint()
 
-				// Wait for resume or cancel or context done
-				select {
-				case <-c.config.Controller.resumeCh:
+				if err := ctrl.waitWhilePaused(ctx, c.config.PauseTimeout); err != nil {
+					return err
+				}
+			case StateCancelled:
+				// The deferred cleanup removes the uploaded chunks
+				return ErrUploadCancelled
+			default:
+				if paused {
 					c.emitEvent(EventUploadResumed, filename, finalPath, "Upload resumed", nil)
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(time.Hour): // Timeout after 1 hour
-					return fmt.Errorf("upload paused timeout")
 				}
+				return nil
+			}
+		}
+	}
 
-			case StateCancelled:
-				// Cleanup and return
-				_ = c.RemoveAll(uploadBase)
-				return fmt.Errorf("upload cancelled")
+	for offset := startOffset; offset < total; offset += chunkSize {
+		// Early cancellation check each iteration
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if ctrl != nil {
+			if err := awaitRunning(); err != nil {
+				return err
+			}
+		}
+		if ctrl.stopRequested() {
+			// Stopping at the chunk boundary: the checkpoint callback's owner
+			// cancels ctx once the checkpoint is saved
+			saveCheckpoint()
+			if err := ctx.Err(); err != nil {
+				return err
 			}
 		}
 
@@ -156,34 +285,65 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 			want = remain
 		}
 
-		n, rerr := io.ReadFull(f, buf[:want])
+		n, rerr := io.ReadFull(r, buf[:want])
 		if rerr != nil && rerr != io.ErrUnexpectedEOF && rerr != io.EOF {
 			return fmt.Errorf("read chunk at %d: %w", offset, rerr)
 		}
+		if source.r != nil && int64(n) < want {
+			// A short stream would otherwise be assembled into a truncated file
+			_ = c.RemoveAll(uploadBase)
+			return fmt.Errorf("read chunk at %d: %w", offset, io.ErrUnexpectedEOF)
+		}
+
+		// Wait for the owner's byte-rate limit, if any
+		if err := c.config.throttle.wait(ctx, int64(n)); err != nil {
+			return err
+		}
 
-		// Retry logic for chunk upload
+		// Retry logic for chunk upload. Pause and Cancel abort the request in
+		// flight; the chunk is sent again once the upload is resumed.
 		var uploadErr error
-		for retry := 0; retry <= c.config.MaxRetries; retry++ {
-			// Check cancellation before each network write
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
-			chunkPath := c.pathJoin(uploadBase, strconv.FormatInt(offset, 10))
-			uploadErr = c.Write(chunkPath, buf[:n], 0o644)
-			if uploadErr == nil {
-				break // Success
-			}
+		for {
+			chunkCtx, stopChunk := ctrl.runningContext(ctx)
+			for retry := 0; retry <= c.config.MaxRetries; retry++ {
+				// Check cancellation before each network write
+				select {
+				case <-ctx.Done():
+					stopChunk()
+					return ctx.Err()
+				default:
+				}
+				if err := chunkCtx.Err(); err != nil {
+					uploadErr = err
+					break // Aborted by the controller
+				}
+				chunkPath := c.pathJoin(uploadBase, strconv.FormatInt(offset, 10))
+				uploadErr = c.putChunk(chunkCtx, chunkPath, buf[:n])
+				if uploadErr == nil {
+					break // Success
+				}
 
-			if retry < c.config.MaxRetries {
-				if c.config.Verbose {
-					log.Printf("chunk upload retry %d/%d for %s: %v", retry+1, c.config.MaxRetries, chunkPath, uploadErr)
+				if retry < c.config.MaxRetries && chunkCtx.Err() == nil {
+					if c.config.Verbose {
+						log.Printf("chunk upload retry %d/%d for %s: %v", retry+1, c.config.MaxRetries, chunkPath, uploadErr)
+					}
 				}
 			}
+			aborted := uploadErr != nil && chunkCtx.Err() != nil && ctx.Err() == nil
+			stopChunk()
+			if !aborted {
+				break
+			}
+			if err := awaitRunning(); err != nil {
+				return err
+			}
 		}
 
 		if uploadErr != nil {
+			// Keep the chunks confirmed since the last checkpoint
+			if chunkIndex > cpChunks {
+				saveCheckpoint()
+			}
 			return &UploadError{
 				Op:      "chunk upload",
 				Path:    c.pathJoin(uploadBase, strconv.FormatInt(offset, 10)),
@@ -225,23 +385,9 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 			log.Printf("chunk %s: +%d bytes (%d/%d, %.1f%%)", chunkPath, n, sent, total, percentage)
 		}
 
-		// Save checkpoint periodically (every 10 chunks)
-		if c.config.CheckpointFunc != nil && chunkIndex%10 == 0 {
-			checkpoint := Checkpoint{
-				LocalPath:          localPath,
-				RemotePath:         finalPath,
-				UploadID:           uploadID,
-				FileSize:           total,
-				ChunkSize:          chunkSize,
-				BytesUploaded:      sent,
-				ChunksUploaded:     chunkIndex,
-				TotalChunks:        totalChunks,
-				Timestamp:          time.Now(),
-				ConfigChunkSize:    c.config.ChunkSize,
-				ConfigSkipExisting: c.config.SkipExisting,
-				ConfigMaxRetries:   c.config.MaxRetries,
-			}
-			c.config.CheckpointFunc(checkpoint)
+		// Save checkpoint periodically (see Config.CheckpointEveryChunks)
+		if c.config.checkpointDue(chunkIndex-cpChunks, sent-cpBytes, time.Since(cpTime)) {
+			saveCheckpoint()
 		}
 	}
 
@@ -252,9 +398,17 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 	c.emitEvent(EventMoveStarted, filename, finalPath, "Starting final move operation", nil)
 	// guard header mutation and rename in case same underlying client is shared
 	c.hdrMu.Lock()
-	c.SetHeader("OC-Total-Length", strconv.FormatInt(total, 10))
+	hdr := make(http.Header)
+	hdr.Set("OC-Total-Length", strconv.FormatInt(total, 10))
+	if !modTime.IsZero() {
+		hdr.Set("X-OC-Mtime", strconv.FormatInt(modTime.Unix(), 10))
+	}
+	if source.checksum != "" {
+		hdr.Set("OC-Checksum", source.checksum)
+	}
+	c.moveHdr.set(hdr)
 	defer func() {
-		c.SetHeader("OC-Total-Length", "")
+		c.moveHdr.set(nil)
 		c.hdrMu.Unlock()
 	}()
 
@@ -274,6 +428,7 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 
 	// Cleanup
 	_ = c.RemoveAll(uploadBase)
+	c.deleteCheckpoint(store, localPath, finalPath)
 
 	if c.config.Verbose {
 		log.Printf("Uploaded (chunked): %s", finalPath)
@@ -282,25 +437,79 @@ func (c *Client) uploadChunked(ctx context.Context, localPath, finalPath string)
 	return nil
 }
 
-// UploadFileResumable uploads a file with built-in pause/resume support
-func (c *Client) UploadFileResumable(localPath, dstPath string) (*UploadController, error) {
-	if c.config == nil {
-		c.config = DefaultConfig()
-	}
+// defaultCheckpointChunks is the checkpoint frequency of uploads that set
+// none of the Config.CheckpointEvery* triggers.
+const defaultCheckpointChunks = 10
 
-	// Create upload controller if not provided
-	if c.config.Controller == nil {
-		c.config.Controller = NewSimpleUploadController()
+// checkpointDue reports whether a checkpoint is due after chunks chunks and
+// bytes bytes were confirmed in elapsed time since the last checkpoint.
+func (cfg *Config) checkpointDue(chunks int, bytes int64, elapsed time.Duration) bool {
+	if chunks == 0 {
+		return false
+	}
+	everyChunks := cfg.CheckpointEveryChunks
+	if everyChunks <= 0 && cfg.CheckpointEveryBytes <= 0 && cfg.CheckpointInterval <= 0 {
+		everyChunks = defaultCheckpointChunks
 	}
+	return (everyChunks > 0 && chunks >= everyChunks) ||
+		(cfg.CheckpointEveryBytes > 0 && bytes >= cfg.CheckpointEveryBytes) ||
+		(cfg.CheckpointInterval > 0 && elapsed >= cfg.CheckpointInterval)
+}
 
-	// Upload in a goroutine to enable pause/resume
-	go func() {
-		err := c.UploadFile(localPath, dstPath)
-		if err != nil && c.config.EventFunc != nil {
-			filename := filepath.Base(localPath)
-			c.emitEvent(EventUploadFailed, filename, dstPath, "Upload failed", err)
+// checkpointKey returns the key of this client's upload of localPath to
+// finalPath in a CheckpointStore.
+func (c *Client) checkpointKey(localPath, finalPath string) CheckpointKey {
+	return CheckpointKey{BaseURL: c.baseURL, Username: c.username, LocalPath: localPath, RemotePath: finalPath}
+}
+
+// storedCheckpoint returns the checkpoint of an upload in store if the upload
+// can continue from it: the local file version and the chunk size are
+// unchanged and the server still has the uploaded chunks. Stale checkpoints are deleted; if
+// the server cannot be asked, the upload tries to continue and the checkpoint
+// is kept.
+func (c *Client) storedCheckpoint(store CheckpointStore, localPath, finalPath string) *Checkpoint {
+	cp, err := store.Load(c.checkpointKey(localPath, finalPath))
+	if err != nil || cp == nil {
+		if err != nil && c.config.Verbose {
+			log.Printf("checkpoint store: %v", err)
 		}
-	}()
+		return nil
+	}
+	if info, err := os.Stat(localPath); err == nil && cp.matchesFile(info, c.config.ChunkSize) {
+		_, err := c.Stat(c.pathJoinMany("uploads", c.username, cp.UploadID))
+		if err == nil || !gowebdav.IsErrNotFound(err) {
+			if err != nil && c.config.Verbose {
+				log.Printf("checkpoint %s: checking uploaded chunks: %v", cp.UploadID, err)
+			}
+			return cp
+		}
+	}
+	c.deleteCheckpoint(store, localPath, finalPath)
+	return nil
+}
 
-	return c.config.Controller, nil
+// deleteCheckpoint removes the checkpoint of a finished upload from store,
+// if any.
+func (c *Client) deleteCheckpoint(store CheckpointStore, localPath, finalPath string) {
+	if store == nil {
+		return
+	}
+	if err := store.Delete(c.checkpointKey(localPath, finalPath)); err != nil && c.config.Verbose {
+		log.Printf("checkpoint store: %v", err)
+	}
+}
+
+// putChunk uploads one chunk of a chunked upload. Unlike Write, the request
+// is bound to ctx, so that pausing or cancelling aborts it.
+func (c *Client) putChunk(ctx context.Context, chunkPath string, data []byte) error {
+	resp, err := c.davRequest(ctx, http.MethodPut, chunkPath, bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("put %s: %s", chunkPath, resp.Status)
+	}
+	return nil
 }
@@ -5,9 +5,28 @@
 //   - client.go: Core client functionality and upload methods
 //   - types.go: Type definitions, constants, and configuration structures
 //   - chunked_upload.go: Chunked upload implementation with retry logic
-//   - upload_controller.go: Pause/resume/cancel functionality for uploads
+//   - dir_upload.go: Recursive directory uploads with aggregate progress
+//   - dir_checkpoint.go: Directory upload journals and ResumeDir
+//   - archive_upload.go: Streaming tar/zip archive uploads
+//   - compare.go: SkipExisting comparison modes and remote checksums
+//   - hash_cache.go: In-memory and persistent local file hash caches
+//   - upload_controller.go: Pause/resume/cancel and state observation for uploads
+//   - upload_handle.go: Background uploads with per-call handles
 //   - upload_manager.go: Multi-session upload coordination and management
+//   - session_store.go: Upload session persistence and restore
+//   - manager_events.go: Upload manager event subscriptions
+//   - session_stats.go: Upload session statistics and manager summary
+//   - session_retry.go: Retry policy for failed upload sessions
+//   - session_group.go: Directory and batch session groups
+//   - session_schedule.go: Upload time windows and scheduled starts
+//   - session_owner.go: Per-owner fairness, rate limits and daily quotas
+//   - session_retention.go: Retention and bulk removal of finished sessions
+//   - manager_shutdown.go: Clean UploadManager shutdown with checkpoints
+//   - manager_pause.go: Global and scoped pause gates of the UploadManager
+//   - session_deps.go: Session dependencies and completion hooks
+//   - share.go: Share creation through the OCS sharing API
 //   - checkpoint.go: Upload resumption and checkpoint persistence
+//   - checkpoint_store.go: File and in-memory checkpoint stores
 //   - buffer_pool.go: Memory-efficient buffer management
 //   - utils.go: Helper functions and utilities
 //
@@ -31,9 +50,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
 	"path/filepath"
 	"sync"
+	"time"
 
 	gowebdav "github.com/studio-b12/gowebdav"
 )
@@ -45,7 +65,16 @@ type Client struct {
 	*gowebdav.Client
 	username string
 	config   *Config
-	hdrMu    sync.Mutex
+	hdrMu    *sync.Mutex
+	moveHdr  *moveHeaders
+	hooks    *requestHooks
+
+	// Used for requests gowebdav cannot express (see davRequest)
+	baseURL string
+	auth    gowebdav.Authorizer
+	http    *http.Client
+
+	hashes HashCache // Default Config.HashCache
 }
 
 // NewClient creates a new Nextcloud WebDAV client.
@@ -61,13 +90,119 @@ type Client struct {
 //
 //	client := godav.NewClient("https://nextcloud.example.com/remote.php/dav/", "username", "password")
 func NewClient(baseURL, username, password string) *Client {
-	return &Client{
-		Client:   gowebdav.NewClient(baseURL, username, password),
+	return NewAuthClient(baseURL, username, gowebdav.NewAutoAuth(username, password))
+}
+
+// NewAuthClient creates a Nextcloud WebDAV client that authenticates with a
+// custom gowebdav Authorizer, e.g. a preemptive bearer token. username is
+// still needed for the paths of chunked uploads. Every request, including
+// those godav sends outside the embedded gowebdav client, is authorized by
+// auth.
+//
+// Example:
+//
+//	auth := gowebdav.NewPreemptiveAuth(myTokenAuth)
+//	client := godav.NewAuthClient("https://nextcloud.example.com/remote.php/dav/", "username", auth)
+func NewAuthClient(baseURL, username string, auth gowebdav.Authorizer) *Client {
+	c := &Client{
+		Client:   gowebdav.NewAuthClient(baseURL, auth),
 		username: username,
 		config:   DefaultConfig(),
+		hdrMu:    &sync.Mutex{},
+		moveHdr:  &moveHeaders{h: make(http.Header)},
+		hooks:    &requestHooks{headers: make(http.Header)},
+		baseURL:  gowebdav.FixSlash(baseURL),
+		auth:     auth,
+		http:     &http.Client{},
+		hashes:   NewMemoryHashCache(),
+	}
+	// gowebdav's SetHeader only ever appends values, so per-upload headers are
+	// added to the finalizing MOVE request by an interceptor instead
+	c.Client.SetInterceptor(c.intercept)
+	return c
+}
+
+// requestHooks holds the request customizations of a client. It is shared
+// with the copies made by withConfig.
+type requestHooks struct {
+	headers     http.Header                           // Added by SetHeader
+	interceptor func(method string, rq *http.Request) // Set by SetInterceptor
+	mu          sync.RWMutex
+}
+
+// intercept is the interceptor installed on the embedded gowebdav client: it
+// adds the pending MOVE headers, then runs the interceptor set by the user.
+func (c *Client) intercept(method string, rq *http.Request) {
+	c.moveHdr.intercept(method, rq)
+	c.hooks.mu.RLock()
+	f := c.hooks.interceptor
+	c.hooks.mu.RUnlock()
+	if f != nil {
+		f(method, rq)
 	}
 }
 
+// withConfig returns a shallow copy of the client that uploads with cfg.
+// The copy shares the underlying WebDAV client and header lock, so it can be
+// used for a single operation without mutating the receiver's config.
+func (c *Client) withConfig(cfg *Config) *Client {
+	clone := &Client{
+		Client:   c.Client,
+		username: c.username,
+		config:   cfg,
+		hdrMu:    c.hdrMu,
+		moveHdr:  c.moveHdr,
+		hooks:    c.hooks,
+		baseURL:  c.baseURL,
+		auth:     c.auth,
+		http:     c.http,
+		hashes:   c.hashes,
+	}
+	clone.config = clone.validateConfig()
+	return clone
+}
+
+// SetHeader adds a header to every request, including those godav sends
+// outside the embedded gowebdav client, such as chunk uploads.
+func (c *Client) SetHeader(key, value string) {
+	c.Client.SetHeader(key, value)
+	c.hooks.mu.Lock()
+	c.hooks.headers.Add(key, value)
+	c.hooks.mu.Unlock()
+}
+
+// SetInterceptor sets a function called with every request before it is sent.
+// Unlike gowebdav's SetInterceptor, it does not replace godav's own
+// interceptor: the function runs after the OC-Total-Length, X-OC-Mtime and
+// OC-Checksum headers were added to the MOVE request that finalizes a
+// chunked upload. Passing nil removes the function.
+func (c *Client) SetInterceptor(interceptor func(method string, rq *http.Request)) {
+	c.hooks.mu.Lock()
+	c.hooks.interceptor = interceptor
+	c.hooks.mu.Unlock()
+}
+
+// SetTimeout sets the time limit for requests, including those godav sends
+// outside the embedded gowebdav client.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.Client.SetTimeout(timeout)
+	c.http.Timeout = timeout
+}
+
+// SetTransport sets the HTTP transport, including for requests godav sends
+// outside the embedded gowebdav client.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.Client.SetTransport(transport)
+	c.http.Transport = transport
+}
+
+// SetJar sets the cookie jar, including for requests godav sends outside the
+// embedded gowebdav client.
+func (c *Client) SetJar(jar http.CookieJar) {
+	c.Client.SetJar(jar)
+	c.http.Jar = jar
+}
+
 // SetVerbose enables or disables verbose logging for upload operations.
 // When enabled, the client will log detailed information about upload progress,
 // chunk operations, and directory creation.
@@ -110,7 +245,8 @@ func (c *Client) SetConfig(cfg *Config) {
 //		}
 //	}
 func (c *Client) UploadFile(localPath, dstPath string) error {
-	return c.uploadFileCore(context.Background(), localPath, dstPath)
+	_, err := c.uploadFileCore(context.Background(), localPath, dstPath)
+	return err
 }
 
 // UploadFileWithConfig uploads a single file using the provided config (does not mutate the client's default config).
@@ -122,7 +258,8 @@ func (c *Client) UploadFileWithConfig(localPath, dstPath string, cfg *Config) er
 	c.config = c.validateConfig()
 	defer func() { c.config = prev }()
 
-	return c.uploadFileCore(context.Background(), localPath, dstPath)
+	_, err := c.uploadFileCore(context.Background(), localPath, dstPath)
+	return err
 }
 
 // UploadFileWithContext uploads a single file with context support for cancellation and timeouts.
@@ -147,7 +284,8 @@ func (c *Client) UploadFileWithConfig(localPath, dstPath string, cfg *Config) er
 //		fmt.Println("Upload timed out")
 //	}
 func (c *Client) UploadFileWithContext(ctx context.Context, localPath, dstPath string) error {
-	return c.uploadFileCore(ctx, localPath, dstPath)
+	_, err := c.uploadFileCore(ctx, localPath, dstPath)
+	return err
 }
 
 // UploadFileWithContextWithConfig uploads with context and the provided config (does not mutate the client's default config).
@@ -158,15 +296,25 @@ func (c *Client) UploadFileWithContextWithConfig(ctx context.Context, localPath,
 	c.config = c.validateConfig()
 	defer func() { c.config = prev }()
 
-	return c.uploadFileCore(ctx, localPath, dstPath)
+	_, err := c.uploadFileCore(ctx, localPath, dstPath)
+	return err
 }
 
 // uploadFileCore contains the core logic for file upload, assuming c.config is already validated.
-func (c *Client) uploadFileCore(ctx context.Context, localPath, dstPath string) error {
+// It reports whether the file was skipped because it already exists remotely.
+func (c *Client) uploadFileCore(ctx context.Context, localPath, dstPath string) (bool, error) {
+	return c.uploadSourceCore(ctx, fileSource(localPath), dstPath)
+}
+
+// uploadSourceCore uploads a local file or stream to dstPath, emitting the
+// per-file events and applying SkipExisting.
+func (c *Client) uploadSourceCore(ctx context.Context, source uploadSource, dstPath string) (bool, error) {
+	localPath := source.localPath
+
 	// Check for cancellation
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return false, ctx.Err()
 	default:
 	}
 
@@ -177,20 +325,28 @@ func (c *Client) uploadFileCore(ctx context.Context, localPath, dstPath string)
 	// Convert to Nextcloud files path and validate
 	cleaned := c.sanitizeRemotePath(dstPath)
 	if cleaned == "" {
-		return fmt.Errorf("invalid remote path")
+		return false, fmt.Errorf("invalid remote path")
 	}
 	finalPath := c.pathJoinMany("files", c.username, cleaned)
 
-	// Skip if exists with same size
+	// Skip if the remote file is unchanged according to CompareMode
 	if c.config.SkipExisting {
-		if info, err := c.Stat(finalPath); err == nil && !info.IsDir() {
-			if localInfo, lerr := os.Stat(localPath); lerr == nil && info.Size() == localInfo.Size() {
-				if c.config.Verbose {
-					log.Printf("Skip unchanged: %s", finalPath)
-				}
-				c.emitEvent(EventUploadSkipped, filename, dstPath, "File already exists with same size", nil)
-				return nil
+		if unchanged, message := c.remoteUnchanged(ctx, finalPath, source); unchanged {
+			if c.config.Verbose {
+				log.Printf("Skip unchanged: %s", finalPath)
+			}
+			c.emitEvent(EventUploadSkipped, filename, dstPath, message, nil)
+			if source.r == nil {
+				c.deleteCheckpoint(c.config.CheckpointStore, localPath, finalPath)
 			}
+			return true, nil
+		}
+	}
+
+	// Let the server record a checksum for later CompareChecksum runs
+	if c.config.CompareMode == CompareChecksum && source.r == nil && source.checksum == "" {
+		if sum, err := c.localChecksum(localPath, "SHA1"); err == nil {
+			source.checksum = "SHA1:" + sum
 		}
 	}
 
@@ -203,53 +359,12 @@ func (c *Client) uploadFileCore(ctx context.Context, localPath, dstPath string)
 		}
 	}
 
-	err := c.uploadChunked(ctx, localPath, finalPath)
+	err := c.uploadChunked(ctx, source, finalPath)
 	if err != nil {
 		c.emitEvent(EventUploadFailed, filename, dstPath, "Upload failed", err)
-		return err
+		return false, err
 	}
 
 	c.emitEvent(EventUploadComplete, filename, dstPath, "Upload completed successfully", nil)
-	return nil
-}
-
-// UploadDir uploads a directory recursively using chunked uploads.
-func (c *Client) UploadDir(localDir, dstDir string) error {
-	c.config = c.validateConfig()
-
-	return filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip the root directory itself
-		if localPath == localDir {
-			return nil
-		}
-
-		// Get relative path
-		rel, err := filepath.Rel(localDir, localPath)
-		if err != nil {
-			return err
-		}
-
-		remotePath := c.pathJoin(dstDir, filepath.ToSlash(rel))
-
-		if info.IsDir() {
-			// Create directory
-			finalPath := c.toFilesPath(remotePath)
-			if err := c.MkdirAll(finalPath, 0o755); err != nil && !c.isAlreadyExists(err) {
-				if c.config.Verbose {
-					log.Printf("mkdir %s: %v", finalPath, err)
-				}
-			}
-		} else {
-			// Upload file
-			if err := c.UploadFile(localPath, remotePath); err != nil {
-				log.Printf("upload %s: %v", remotePath, err)
-			}
-		}
-
-		return nil
-	})
+	return false, nil
 }
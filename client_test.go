@@ -1,12 +1,31 @@
 package godav
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	gowebdav "github.com/studio-b12/gowebdav"
 )
 
 // mockClient is a minimal stub for testing purposes.
@@ -651,19 +670,328 @@ func TestSaveAndLoadCheckpoint(t *testing.T) {
 	}
 }
 
+func TestCheckpointStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	const server = "https://nc.example.com/remote.php/dav/"
+	older := Checkpoint{BaseURL: server, Username: "user", LocalPath: "/data/a.bin", RemotePath: "files/user/dst/a.bin", UploadID: "a", Timestamp: now.Add(-time.Minute)}
+	newer := Checkpoint{BaseURL: server, Username: "user", LocalPath: "/data/b.bin", RemotePath: "files/user/dst/b.bin", UploadID: "b", Timestamp: now}
+	for _, cp := range []Checkpoint{newer, older} {
+		if err := store.Save(cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Remote paths are matched with or without the files/<user> prefix
+	key := CheckpointKey{BaseURL: server, Username: "user", LocalPath: "/data/a.bin", RemotePath: "dst/a.bin"}
+	if cp, err := store.Load(key); err != nil || cp == nil || cp.UploadID != "a" {
+		t.Fatalf("expected the stored checkpoint, got %+v (%v)", cp, err)
+	}
+	for _, other := range []CheckpointKey{
+		{BaseURL: server, Username: "user", LocalPath: "/data/c.bin", RemotePath: "dst/c.bin"},
+		{BaseURL: server, Username: "other", LocalPath: "/data/a.bin", RemotePath: "dst/a.bin"},
+		{BaseURL: "https://other.example.com/remote.php/dav/", Username: "user", LocalPath: "/data/a.bin", RemotePath: "dst/a.bin"},
+	} {
+		if cp, err := store.Load(other); err != nil || cp != nil {
+			t.Fatalf("expected no checkpoint for %+v, got %+v (%v)", other, cp, err)
+		}
+	}
+	if cps, err := store.List(); err != nil || len(cps) != 2 || cps[0].UploadID != "a" {
+		t.Fatalf("expected both checkpoints, oldest first, got %+v (%v)", cps, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if info, _ := e.Info(); !strings.HasSuffix(e.Name(), ".json") || info.Mode().Perm() != 0o600 {
+			t.Errorf("unexpected store file %s (%v)", e.Name(), info.Mode())
+		}
+	}
+	if err := store.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if cps, _ := store.List(); len(cps) != 1 {
+		t.Fatalf("expected one checkpoint after Delete, got %d", len(cps))
+	}
+
+	// Uploads save to the store, resume from it and delete their checkpoint
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	mem := NewMemoryCheckpointStore()
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.MaxRetries = 0
+	cfg.CheckpointStore = mem
+	c.SetConfig(cfg)
+	content := strings.Repeat("s", 12*1024)
+	local := filepath.Join(writeTestTree(t, map[string]string{"big.bin": content}), "big.bin")
+
+	fs.failPut = func(p string, data []byte) bool { return strings.HasSuffix(p, "/"+strconv.Itoa(11*1024)) }
+	if err := c.UploadFile(local, "dst/big.bin"); err == nil {
+		t.Fatal("expected the last chunk to fail")
+	}
+	cp, _ := mem.Load(c.checkpointKey(local, "dst/big.bin"))
+	if cp == nil || cp.ChunksUploaded != 11 || cp.Username != "user" {
+		t.Fatalf("expected a stored checkpoint of the 11 confirmed chunks, got %+v", cp)
+	}
+
+	fs.mu.Lock()
+	fs.failPut = nil
+	fs.mu.Unlock()
+	putsBefore := fs.putCount()
+	if err := c.UploadFile(local, "dst/big.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.putCount() - putsBefore; n != 1 {
+		t.Fatalf("expected the upload to resume from the stored checkpoint, got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+	if cps, _ := mem.List(); len(cps) != 0 {
+		t.Fatalf("expected the checkpoint to be deleted on completion, got %d", len(cps))
+	}
+}
+
+func TestCheckpointStore_RewrittenFile(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	store := NewMemoryCheckpointStore()
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.MaxRetries = 0
+	cfg.CheckpointStore = store
+	c.SetConfig(cfg)
+	local := filepath.Join(writeTestTree(t, map[string]string{"disk.img": strings.Repeat("a", 4*1024)}), "disk.img")
+
+	fs.failPut = func(p string, data []byte) bool { return strings.HasSuffix(p, "/3072") }
+	if err := c.UploadFile(local, "disk.img"); err == nil {
+		t.Fatal("expected the last chunk to fail")
+	}
+	cp, _ := store.Load(c.checkpointKey(local, "disk.img"))
+	if cp == nil || cp.ModTime.IsZero() {
+		t.Fatalf("expected a checkpoint with the file's mtime, got %+v", cp)
+	}
+
+	// Rewritten in place at the same size: the old chunks must not be reused
+	content := strings.Repeat("b", 4*1024)
+	if err := os.WriteFile(local, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := cp.ModTime.Add(time.Minute)
+	if err := os.Chtimes(local, later, later); err != nil {
+		t.Fatal(err)
+	}
+	fs.mu.Lock()
+	fs.failPut = nil
+	fs.mu.Unlock()
+	putsBefore := fs.putCount()
+	if err := c.UploadFile(local, "disk.img"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.putCount() - putsBefore; n != 4 {
+		t.Fatalf("expected the changed file to be uploaded from the start, got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/user/disk.img"); string(data) != content {
+		t.Fatalf("expected the new content, got a mix")
+	}
+
+	// The manager and directory journals use the same check
+	info, err := os.Stat(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(&Checkpoint{FileSize: info.Size(), ModTime: info.ModTime(), ChunkSize: 1024}).matchesFile(info, 1024) {
+		t.Error("expected a checkpoint of the current version to match")
+	}
+	if cp.matchesFile(info, 1024) {
+		t.Error("expected the checkpoint of the old version not to match")
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCheckpointStore_SharedAndTransientErrors(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	store := NewMemoryCheckpointStore()
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.MaxRetries = 0
+	cfg.CheckpointStore = store
+	alice, bob := fs.client("alice"), fs.client("bob")
+	alice.SetConfig(cfg)
+	bob.SetConfig(cfg)
+	content := strings.Repeat("s", 4*1024)
+	local := filepath.Join(writeTestTree(t, map[string]string{"big.bin": content}), "big.bin")
+
+	fs.failPut = func(p string, data []byte) bool {
+		return strings.HasPrefix(p, "uploads/alice/") && strings.HasSuffix(p, "/3072")
+	}
+	if err := alice.UploadFile(local, "dst/big.bin"); err == nil {
+		t.Fatal("expected the last chunk to fail")
+	}
+
+	// Another user uploading the same file never sees alice's checkpoint
+	if err := bob.UploadFile(local, "dst/big.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := store.Load(alice.checkpointKey(local, "dst/big.bin")); cp == nil || cp.ChunksUploaded != 3 {
+		t.Fatalf("expected alice's checkpoint to survive bob's upload, got %+v", cp)
+	}
+
+	// A failed check of the uploaded chunks keeps the checkpoint
+	fs.mu.Lock()
+	fs.failPut = nil
+	fs.mu.Unlock()
+	var failedStat atomic.Bool
+	alice.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == "PROPFIND" && strings.Contains(r.URL.Path, "/uploads/alice/") && failedStat.CompareAndSwap(false, true) {
+			return nil, errors.New("connection reset")
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	}))
+	putsBefore := fs.putCount()
+	if err := alice.UploadFile(local, "dst/big.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if !failedStat.Load() {
+		t.Fatal("expected the chunk check to be attempted")
+	}
+	if n := fs.putCount() - putsBefore; n != 1 {
+		t.Fatalf("expected the upload to resume from the kept checkpoint, got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/alice/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+}
+
+func TestCheckpointFrequency(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	local := filepath.Join(writeTestTree(t, map[string]string{"f.bin": strings.Repeat("f", 25*1024)}), "f.bin")
+
+	tests := []struct {
+		name string
+		set  func(cfg *Config)
+		want []int // ChunksUploaded of the saved checkpoints
+	}{
+		{"default", func(cfg *Config) {}, []int{10, 20}},
+		{"chunks", func(cfg *Config) { cfg.CheckpointEveryChunks = 8 }, []int{8, 16, 24}},
+		{"bytes", func(cfg *Config) { cfg.CheckpointEveryBytes = 6 * 1024 }, []int{6, 12, 18, 24}},
+		{"chunks or bytes", func(cfg *Config) {
+			cfg.CheckpointEveryChunks = 20
+			cfg.CheckpointEveryBytes = 9 * 1024
+		}, []int{9, 18}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fs.client("user")
+			cfg := DefaultConfig()
+			cfg.ChunkSize = 1024
+			var got []int
+			cfg.CheckpointFunc = func(cp Checkpoint) { got = append(got, cp.ChunksUploaded) }
+			tt.set(cfg)
+			c.SetConfig(cfg)
+			if err := c.UploadFile(local, "dst/"+tt.name+".bin"); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected checkpoints after %v chunks, got %v", tt.want, got)
+			}
+		})
+	}
+
+	cfg := &Config{CheckpointInterval: 500 * time.Millisecond}
+	if cfg.checkpointDue(1, 1024, 100*time.Millisecond) || !cfg.checkpointDue(1, 1024, time.Second) {
+		t.Fatal("expected a checkpoint only once the interval elapsed")
+	}
+	if cfg.checkpointDue(0, 0, time.Hour) {
+		t.Fatal("expected no checkpoint without new chunks")
+	}
+}
+
 func TestUploadFileResumable(t *testing.T) {
-	c := NewClient("http://example.com", "user", "pass")
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{"a.bin": strings.Repeat("a", 3*1024), "b.bin": strings.Repeat("b", 2*1024)})
+
+	if _, err := c.UploadFileResumable(filepath.Join(root, "missing.bin"), "dst/missing.bin"); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+
+	// The first chunk of the first upload hangs until its request is aborted
+	var puts atomic.Int32
+	started := make(chan struct{})
+	fs.holdPut = func(r *http.Request) {
+		if puts.Add(1) == 1 {
+			_, _ = io.ReadAll(r.Body)
+			close(started)
+			<-r.Context().Done()
+		}
+	}
+	a, err := c.UploadFileResumable(filepath.Join(root, "a.bin"), "dst/a.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	a.Pause()
 
-	// Test that controller is created
-	controller, err := c.UploadFileResumable("/nonexistent", "remote")
+	// A second upload on the same client gets its own handle
+	b, err := c.UploadFileResumable(filepath.Join(root, "b.bin"), "dst/b.bin")
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatal(err)
 	}
-	if controller == nil {
-		t.Error("expected controller to be created")
+	result, err := b.Wait()
+	if err != nil {
+		t.Fatalf("second upload failed: %v", err)
 	}
-	if controller.State() != StateRunning {
-		t.Errorf("expected initial state to be StateRunning, got %v", controller.State())
+	if result.Size != 2048 || result.RemotePath != "dst/b.bin" || b.Progress().Current != 2048 {
+		t.Fatalf("unexpected result %+v (progress %+v)", result, b.Progress())
+	}
+	if b.Controller() == a.Controller() || a.Controller().State() != StatePaused {
+		t.Fatalf("expected the first upload to stay paused on its own controller, got %s", a.Controller().State())
+	}
+	select {
+	case <-a.Done():
+		t.Fatal("expected the paused upload to be unfinished")
+	default:
+	}
+
+	a.Cancel()
+	if _, err := a.Wait(); !errors.Is(err, ErrUploadCancelled) {
+		t.Fatalf("expected ErrUploadCancelled, got %v", err)
+	}
+	if a.Controller().State() != StateCancelled || b.Controller().State() != StateCompleted {
+		t.Fatalf("unexpected terminal states %s and %s", a.Controller().State(), b.Controller().State())
+	}
+
+	// A failed upload reports its failure event once
+	var failedEvents atomic.Int32
+	cfg.MaxRetries = 0
+	cfg.EventFunc = func(info EventInfo) {
+		if info.Event == EventUploadFailed {
+			failedEvents.Add(1)
+		}
+	}
+	c.SetConfig(cfg)
+	fs.holdPut = nil
+	fs.failPut = func(string, []byte) bool { return true }
+	failed, err := c.UploadFileResumable(filepath.Join(root, "b.bin"), "dst/failed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := failed.Wait(); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if n := failedEvents.Load(); n != 1 {
+		t.Fatalf("expected 1 %s event, got %d", EventUploadFailed, n)
 	}
 }
 
@@ -967,6 +1295,133 @@ func TestUploadController_PauseResume(t *testing.T) {
 	}
 }
 
+func TestUploadController_PromptPauseAndCancel(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	content := strings.Repeat("p", 3*1024)
+	local := filepath.Join(writeTestTree(t, map[string]string{"f.bin": content}), "f.bin")
+
+	// The second chunk hangs until its request is aborted
+	var hang atomic.Bool
+	started, aborted := make(chan struct{}, 1), make(chan struct{}, 1)
+	fs.holdPut = func(r *http.Request) {
+		if hang.Load() && strings.HasSuffix(r.URL.Path, "/1024") {
+			_, _ = io.ReadAll(r.Body) // Lets the server notice the client going away
+			started <- struct{}{}
+			<-r.Context().Done()
+			aborted <- struct{}{}
+		}
+	}
+	upload := func(dst string, pauseTimeout time.Duration) (*UploadController, chan error) {
+		c := fs.client("user")
+		cfg := DefaultConfig()
+		cfg.ChunkSize = 1024
+		cfg.Controller = NewSimpleUploadController()
+		cfg.PauseTimeout = pauseTimeout
+		c.SetConfig(cfg)
+		hang.Store(true)
+		errc := make(chan error, 1)
+		go func() { errc <- c.UploadFile(local, dst) }()
+		<-started
+		cfg.Controller.Pause()
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected Pause to abort the chunk in flight")
+		}
+		hang.Store(false)
+		return cfg.Controller, errc
+	}
+	result := func(errc chan error) error {
+		select {
+		case err := <-errc:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("upload did not finish")
+			return nil
+		}
+	}
+
+	// Resume sends the aborted chunk again
+	ctrl, errc := upload("dst/resumed.bin", 0)
+	ctrl.Resume()
+	if err := result(errc); err != nil {
+		t.Fatalf("resumed upload failed: %v", err)
+	}
+	if data, _ := fs.file("files/user/dst/resumed.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+
+	// Cancel wakes a paused upload
+	ctrl, errc = upload("dst/cancelled.bin", 0)
+	ctrl.Cancel()
+	if err := result(errc); !errors.Is(err, ErrUploadCancelled) {
+		t.Fatalf("expected ErrUploadCancelled, got %v", err)
+	}
+
+	// A pause outlasting PauseTimeout fails the upload
+	_, errc = upload("dst/timeout.bin", 50*time.Millisecond)
+	if err := result(errc); !errors.Is(err, ErrPauseTimeout) {
+		t.Fatalf("expected ErrPauseTimeout, got %v", err)
+	}
+}
+
+func TestUploadController_StateSubscription(t *testing.T) {
+	ctrl := NewSimpleUploadController()
+	changes, unsubscribe := ctrl.Subscribe()
+	defer unsubscribe()
+
+	ctrl.Pause()
+	ctrl.Resume()
+	boom := errors.New("boom")
+	ctrl.finish(boom)
+	ctrl.Cancel() // No effect on a finished upload
+
+	var got []string
+	for change := range changes {
+		got = append(got, change.From.String()+"->"+change.To.String())
+		if change.To == StateFailed && change.Err != boom {
+			t.Errorf("expected the failure cause with the transition, got %v", change.Err)
+		}
+	}
+	if want := []string{"running->paused", "paused->running", "running->failed"}; !slices.Equal(got, want) {
+		t.Fatalf("expected transitions %v, got %v", want, got)
+	}
+	if ctrl.State() != StateFailed || ctrl.Err() != boom {
+		t.Fatalf("expected failed state with its cause, got %s (%v)", ctrl.State(), ctrl.Err())
+	}
+	if err := ctrl.WaitForState(context.Background(), StateCompleted); !errors.Is(err, boom) {
+		t.Fatalf("expected WaitForState to report the failure, got %v", err)
+	}
+	if late, _ := ctrl.Subscribe(); !isClosed(late) {
+		t.Fatal("expected a closed channel for a finished upload")
+	}
+
+	// The manager finishes the controllers of its sessions
+	fs := newFakeNextcloud(t)
+	root := writeTestTree(t, map[string]string{"a.txt": "aaa"})
+	manager := NewUploadManager()
+	session, err := manager.AddUploadSession(filepath.Join(root, "a.txt"), "dst/a.txt", fs.client("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = manager.StartUpload(session.ID) }()
+	if err := session.Controller.WaitForState(ctx, StateCompleted); err != nil {
+		t.Fatalf("expected the session's controller to complete: %v", err)
+	}
+}
+
+// isClosed reports whether a state subscription is closed and drained.
+func isClosed(ch <-chan StateChange) bool {
+	select {
+	case _, ok := <-ch:
+		return !ok
+	default:
+		return false
+	}
+}
+
 func TestUploadStatus_String(t *testing.T) {
 	statuses := []UploadStatus{
 		StatusQueued,
@@ -1162,3 +1617,2394 @@ func TestMultiClientUploadCoordination(t *testing.T) {
 		t.Errorf("expected 2 remaining sessions, got %d", len(remainingSessions))
 	}
 }
+
+// fakeNextcloud is a minimal in-memory Nextcloud WebDAV server that
+// understands the chunked upload protocol (MKCOL, PUT, MOVE of .file).
+type fakeNextcloud struct {
+	*httptest.Server
+	mu        sync.Mutex
+	files     map[string][]byte    // path -> content
+	mtimes    map[string]time.Time // path -> modification time
+	checksums map[string]string    // path -> oc:checksums value recorded from OC-Checksum
+	dirs      map[string]bool
+	puts      []string                            // paths of successful PUTs, in order
+	shares    []string                            // paths shared through the OCS API
+	failPut   func(path string, data []byte) bool // optional PUT failure injection
+	holdPut   func(r *http.Request)               // optional hook run before a PUT, without fs.mu
+	auth      string                              // optional Authorization header required on every request
+}
+
+func newFakeNextcloud(t *testing.T) *fakeNextcloud {
+	t.Helper()
+	fs := &fakeNextcloud{
+		files:     make(map[string][]byte),
+		mtimes:    make(map[string]time.Time),
+		checksums: make(map[string]string),
+		dirs:      make(map[string]bool),
+	}
+	fs.Server = httptest.NewServer(http.HandlerFunc(fs.handle))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+// client returns a godav client pointed at the fake server.
+func (fs *fakeNextcloud) client(user string) *Client {
+	return NewClient(fs.URL+"/remote.php/dav/", user, "pass")
+}
+
+func (fs *fakeNextcloud) davPath(raw string) string {
+	p := strings.TrimPrefix(raw, fs.URL)
+	p, _ = url.PathUnescape(p)
+	p = strings.TrimPrefix(p, "/remote.php/dav/")
+	return strings.Trim(p, "/")
+}
+
+// file returns the content stored at path and whether it exists.
+func (fs *fakeNextcloud) file(path string) ([]byte, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	data, ok := fs.files[path]
+	return data, ok
+}
+
+// putFile stores a file directly, bypassing the upload protocol.
+func (fs *fakeNextcloud) putFile(path string, data []byte, mtime time.Time) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.files[path] = data
+	fs.mtimes[path] = mtime
+}
+
+func (fs *fakeNextcloud) handle(w http.ResponseWriter, r *http.Request) {
+	if fs.auth != "" && r.Header.Get("Authorization") != fs.auth {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("WWW-Authenticate", `Basic realm="Nextcloud"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if fs.holdPut != nil && r.Method == http.MethodPut {
+		fs.holdPut(r)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p := fs.davPath(r.URL.Path)
+	switch r.Method {
+	case http.MethodPost:
+		if p != "ocs/v2.php/apps/files_sharing/api/v1/shares" || r.Header.Get("OCS-APIRequest") != "true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		shared := r.PostFormValue("path")
+		fs.shares = append(fs.shares, shared)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ocs":{"meta":{"status":"ok","statuscode":200,"message":"OK"},"data":{"id":%d,"path":%q,"url":"%s/s/tok%d","token":"tok%d"}}}`,
+			len(fs.shares), shared, fs.URL, len(fs.shares), len(fs.shares))
+	case "MKCOL":
+		if fs.dirs[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		fs.dirs[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if fs.failPut != nil && fs.failPut(p, data) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Context().Err() != nil {
+			return // Aborted by the client, like a truncated body
+		}
+		fs.files[p] = data
+		fs.mtimes[p] = time.Now()
+		fs.puts = append(fs.puts, p)
+		w.WriteHeader(http.StatusCreated)
+	case "MOVE":
+		dst := fs.davPath(r.Header.Get("Destination"))
+		if strings.HasSuffix(p, "/.file") {
+			base := strings.TrimSuffix(p, ".file")
+			var offsets []int64
+			for name := range fs.files {
+				if rest, ok := strings.CutPrefix(name, base); ok {
+					if off, err := strconv.ParseInt(rest, 10, 64); err == nil {
+						offsets = append(offsets, off)
+					}
+				}
+			}
+			sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+			var data []byte
+			for _, off := range offsets {
+				data = append(data, fs.files[base+strconv.FormatInt(off, 10)]...)
+			}
+			if total := r.Header.Values("OC-Total-Length"); len(total) != 1 || total[0] != strconv.Itoa(len(data)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fs.files[dst] = data
+		} else if data, ok := fs.files[p]; ok {
+			fs.files[dst] = data
+			delete(fs.files, p)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fs.mtimes[dst] = time.Now()
+		delete(fs.checksums, dst)
+		if sum := r.Header.Get("OC-Checksum"); sum != "" {
+			fs.checksums[dst] = sum
+		}
+		if mt := r.Header.Get("X-OC-Mtime"); mt != "" {
+			if sec, err := strconv.ParseInt(mt, 10, 64); err == nil {
+				fs.mtimes[dst] = time.Unix(sec, 0)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+	case "COPY":
+		data, ok := fs.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		dst := fs.davPath(r.Header.Get("Destination"))
+		fs.files[dst] = data
+		fs.mtimes[dst] = fs.mtimes[p]
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		for name := range fs.files {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				delete(fs.files, name)
+			}
+		}
+		for name := range fs.dirs {
+			if name == p || strings.HasPrefix(name, p+"/") {
+				delete(fs.dirs, name)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "PROPFIND":
+		var prop string
+		if data, ok := fs.files[p]; ok {
+			prop = fmt.Sprintf("<d:getcontentlength>%d</d:getcontentlength><d:getlastmodified>%s</d:getlastmodified><d:resourcetype/>",
+				len(data), fs.mtimes[p].UTC().Format(http.TimeFormat))
+			if sum, ok := fs.checksums[p]; ok {
+				prop += "<oc:checksums><oc:checksum>" + sum + "</oc:checksum></oc:checksums>"
+			}
+		} else if fs.dirs[p] {
+			prop = "<d:resourcetype><d:collection/></d:resourcetype>"
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response></d:multistatus>`,
+			r.URL.Path, prop)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// putCount returns the number of successful PUTs seen so far.
+func (fs *fakeNextcloud) putCount() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.puts)
+}
+
+// writeTestTree creates files (relative path -> content) below a temp dir.
+func writeTestTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestUploadDir_AggregateProgress(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{
+		"a.txt":       strings.Repeat("a", 3000),
+		"sub/b.txt":   strings.Repeat("b", 1500),
+		"sub/c/d.txt": "d",
+	})
+
+	var events []UploadEvent
+	var last DirProgressInfo
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.SkipExisting = false
+	cfg.EventFunc = func(info EventInfo) { events = append(events, info.Event) }
+	cfg.DirProgressFunc = func(info DirProgressInfo) { last = info }
+	c.SetConfig(cfg)
+
+	if _, err := c.UploadDir(root, "backup"); err != nil {
+		t.Fatalf("UploadDir: %v", err)
+	}
+
+	if last.FilesTotal != 3 || last.FilesDone != 3 {
+		t.Errorf("expected 3/3 files, got %d/%d", last.FilesDone, last.FilesTotal)
+	}
+	if last.BytesTotal != 4501 || last.BytesDone != 4501 {
+		t.Errorf("expected 4501/4501 bytes, got %d/%d", last.BytesDone, last.BytesTotal)
+	}
+	if last.Percentage != 100.0 {
+		t.Errorf("expected 100%%, got %.1f", last.Percentage)
+	}
+	if len(last.CurrentFiles) != 0 {
+		t.Errorf("expected no current files at the end, got %v", last.CurrentFiles)
+	}
+	if events[0] != EventDirScanStarted || events[1] != EventDirScanDone || events[len(events)-1] != EventDirComplete {
+		t.Errorf("unexpected directory event order: %v", events)
+	}
+	if data, ok := fs.file("files/user/backup/sub/b.txt"); !ok || len(data) != 1500 {
+		t.Errorf("expected sub/b.txt to be uploaded with 1500 bytes, got %d (exists=%t)", len(data), ok)
+	}
+}
+
+func TestUploadDir_ResultAndRetryFailures(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{
+		"ok.txt":     "fine",
+		"bad.txt":    "broken",
+		"exists.txt": "same",
+	})
+	fs.putFile("files/user/dst/exists.txt", []byte("same"), time.Now())
+
+	failing := true
+	fs.failPut = func(p string, data []byte) bool { return failing && string(data) == "broken" }
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	c.SetConfig(cfg)
+
+	result, err := c.UploadDir(root, "dst")
+	if err == nil {
+		t.Fatal("expected an error when a file fails")
+	}
+	var uploadErr *UploadError
+	if !errors.As(err, &uploadErr) || uploadErr.Op != "upload dir" {
+		t.Errorf("expected an UploadError for the directory, got %v", err)
+	}
+	if result == nil {
+		t.Fatal("expected a result alongside the error")
+	}
+	if len(result.Failed) != 1 || result.Failed[0].RelPath != "bad.txt" || result.Failed[0].Err == nil {
+		t.Fatalf("expected bad.txt to be reported as failed, got %+v", result.Failed)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].RelPath != "exists.txt" {
+		t.Errorf("expected exists.txt to be skipped, got %+v", result.Skipped)
+	}
+	if len(result.Uploaded) != 1 || result.Uploaded[0].RelPath != "ok.txt" || result.BytesUploaded != 4 {
+		t.Errorf("expected ok.txt to be uploaded, got %+v", result.Uploaded)
+	}
+
+	failuresPath := filepath.Join(t.TempDir(), "failures.json")
+	if err := result.WriteFailures(failuresPath); err != nil {
+		t.Fatalf("WriteFailures: %v", err)
+	}
+
+	failing = false
+	retry, err := c.RetryDirFailures(failuresPath)
+	if err != nil {
+		t.Fatalf("RetryDirFailures: %v", err)
+	}
+	if len(retry.Uploaded) != 1 || retry.Uploaded[0].RelPath != "bad.txt" {
+		t.Errorf("expected only bad.txt to be retried, got %+v", retry.Uploaded)
+	}
+	if data, ok := fs.file("files/user/dst/bad.txt"); !ok || string(data) != "broken" {
+		t.Errorf("expected bad.txt to exist after retry, got %q", data)
+	}
+
+	// A directory result without a local directory is still not an archive
+	bare := &DirUploadResult{RemoteDir: "dst", Failed: []DirEntryResult{{RelPath: "bad.txt", Err: errors.New("boom")}}}
+	if !errors.As(bare.Err(), &uploadErr) || uploadErr.Op != "upload dir" {
+		t.Errorf("expected an upload dir error, got %v", bare.Err())
+	}
+}
+
+func TestUploadDir_FailFast(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+	fs.failPut = func(p string, data []byte) bool { return strings.HasPrefix(p, "uploads/") }
+
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	cfg.DirFailFast = true
+	c.SetConfig(cfg)
+
+	result, err := c.UploadDir(root, "dst")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(result.Failed) != 1 || len(result.Uploaded) != 0 {
+		t.Errorf("expected to stop after the first failure, got %d failed and %d uploaded",
+			len(result.Failed), len(result.Uploaded))
+	}
+}
+
+func TestUploadDir_SymlinkSpecialAndHiddenPolicies(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"real/file.txt": "data",
+		".hidden":       "secret",
+	})
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "linkdir")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// A link back to the root creates a cycle when followed
+	if err := os.Symlink(root, filepath.Join(root, "real", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := func(result *DirUploadResult) map[string]string {
+		m := make(map[string]string)
+		for _, s := range result.Skipped {
+			m[s.RelPath] = s.Reason
+		}
+		return m
+	}
+
+	t.Run("skip by default", func(t *testing.T) {
+		fs := newFakeNextcloud(t)
+		c := fs.client("user")
+
+		result, err := c.UploadDir(root, "dst")
+		if err != nil {
+			t.Fatalf("UploadDir: %v", err)
+		}
+		if _, ok := fs.file("files/user/dst/real/file.txt"); !ok {
+			t.Error("expected regular files to be uploaded")
+		}
+		if _, ok := fs.file("files/user/dst/linkdir/file.txt"); ok {
+			t.Error("expected the linked directory not to be followed")
+		}
+		skipped := reasons(result)
+		if skipped["linkdir"] != "symlink" || skipped["real/loop"] != "symlink" {
+			t.Errorf("expected links to be skipped: %v", skipped)
+		}
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		fs := newFakeNextcloud(t)
+		c := fs.client("user")
+		cfg := DefaultConfig()
+		cfg.SkipHidden = true
+		cfg.SymlinkPolicy = SymlinkFollow
+		c.SetConfig(cfg)
+
+		result, err := c.UploadDir(root, "dst")
+		if err != nil {
+			t.Fatalf("UploadDir: %v", err)
+		}
+		if _, ok := fs.file("files/user/dst/linkdir/file.txt"); !ok {
+			t.Error("expected the linked directory to be followed")
+		}
+		skipped := reasons(result)
+		if skipped[".hidden"] != "hidden" || skipped["pipe"] != "named pipe" {
+			t.Errorf("unexpected skip reasons: %v", skipped)
+		}
+		if skipped["real/loop"] != "symlink cycle" || skipped["linkdir/loop"] != "symlink cycle" {
+			t.Errorf("expected loop links to be reported as cycles: %v", skipped)
+		}
+	})
+
+	t.Run("as file", func(t *testing.T) {
+		fs := newFakeNextcloud(t)
+		c := fs.client("user")
+		cfg := DefaultConfig()
+		cfg.SymlinkPolicy = SymlinkAsFile
+		c.SetConfig(cfg)
+
+		if _, err := c.UploadDir(root, "dst"); err != nil {
+			t.Fatalf("UploadDir: %v", err)
+		}
+		data, ok := fs.file("files/user/dst/linkdir.symlink")
+		if !ok || !strings.Contains(string(data), filepath.Join(root, "real")) {
+			t.Errorf("expected a link-description file, got %q", data)
+		}
+		if _, ok := fs.file("files/user/dst/.hidden"); !ok {
+			t.Error("expected hidden files to be uploaded by default")
+		}
+	})
+}
+
+func TestUploadDir_JournalAndResumeDir(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{
+		"a.txt":     "alpha",
+		"b.txt":     "bravo",
+		"sub/c.txt": "charlie",
+	})
+	journalPath := filepath.Join(t.TempDir(), "dir.journal")
+
+	failing := true
+	fs.failPut = func(p string, data []byte) bool { return failing && string(data) == "bravo" }
+	cfg := DefaultConfig()
+	cfg.MaxRetries = 0
+	cfg.DirCheckpointPath = journalPath
+	c.SetConfig(cfg)
+
+	if _, err := c.UploadDir(root, "dst"); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	state, err := LoadDirCheckpoint(journalPath)
+	if err != nil {
+		t.Fatalf("expected the journal to be kept after a failure: %v", err)
+	}
+	if state.LocalDir != root || state.RemoteDir != "dst" {
+		t.Errorf("unexpected journal header: %q -> %q", state.LocalDir, state.RemoteDir)
+	}
+	if !state.Completed["a.txt"] || !state.Completed["sub/c.txt"] || state.Completed["b.txt"] {
+		t.Errorf("unexpected completed set: %v", state.Completed)
+	}
+
+	failing = false
+	before := fs.putCount()
+	result, err := c.ResumeDir(journalPath)
+	if err != nil {
+		t.Fatalf("ResumeDir: %v", err)
+	}
+	if len(result.Uploaded) != 1 || result.Uploaded[0].RelPath != "b.txt" {
+		t.Errorf("expected only b.txt to be uploaded on resume, got %+v", result.Uploaded)
+	}
+	if result.AlreadyDone != 3 { // a.txt, sub and sub/c.txt
+		t.Errorf("expected 3 entries already done, got %d", result.AlreadyDone)
+	}
+	if puts := fs.putCount() - before; puts != 1 {
+		t.Errorf("expected a single chunk PUT on resume, got %d", puts)
+	}
+	if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed after success, got %v", err)
+	}
+}
+
+func TestResumeDir_InFlightCheckpoint(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	content := strings.Repeat("x", 1024) + strings.Repeat("y", 1024) + "z"
+	root := writeTestTree(t, map[string]string{"big.bin": content})
+
+	// Simulate a previous run that uploaded the first chunk before dying
+	fs.putFile("uploads/user/up-1/0", []byte(content[:1024]), time.Now())
+	info, err := os.Stat(filepath.Join(root, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := Checkpoint{
+		LocalPath:      filepath.Join(root, "big.bin"),
+		RemotePath:     "files/user/dst/big.bin",
+		UploadID:       "up-1",
+		FileSize:       int64(len(content)),
+		ModTime:        info.ModTime(),
+		ChunkSize:      1024,
+		BytesUploaded:  1024,
+		ChunksUploaded: 1,
+		TotalChunks:    3,
+	}
+	journalPath := filepath.Join(t.TempDir(), "dir.journal")
+	j, err := createDirJournal(journalPath, root, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.saveCheckpoint("big.bin", cp); err != nil {
+		t.Fatal(err)
+	}
+	_ = j.close(false)
+
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+
+	if _, err := c.ResumeDir(journalPath); err != nil {
+		t.Fatalf("ResumeDir: %v", err)
+	}
+	data, ok := fs.file("files/user/dst/big.bin")
+	if !ok || string(data) != content {
+		t.Fatalf("expected the resumed file to be complete, got %d bytes", len(data))
+	}
+	if fs.putCount() != 2 {
+		t.Errorf("expected only the two remaining chunks to be uploaded, got %d PUTs", fs.putCount())
+	}
+}
+
+func TestUploadArchive_TarGz(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.SkipHidden = true
+	cfg.SymlinkPolicy = SymlinkAsFile
+	c.SetConfig(cfg)
+
+	mtime := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	big := strings.Repeat("a", 2500)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	entries := []struct {
+		hdr  tar.Header
+		body string
+	}{
+		{hdr: tar.Header{Name: "site/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		{hdr: tar.Header{Name: "site/index.html", Typeflag: tar.TypeReg, Mode: 0o644, ModTime: mtime}, body: "<html/>"},
+		{hdr: tar.Header{Name: "site/assets/big.bin", Typeflag: tar.TypeReg, Mode: 0o644, ModTime: mtime}, body: big},
+		{hdr: tar.Header{Name: "site/.env", Typeflag: tar.TypeReg, Mode: 0o600}, body: "SECRET=1"},
+		{hdr: tar.Header{Name: "site/latest", Typeflag: tar.TypeSymlink, Linkname: "index.html"}},
+		{hdr: tar.Header{Name: "site/copy/index.html", Typeflag: tar.TypeLink, Linkname: "site/index.html"}},
+		{hdr: tar.Header{Name: "site/orphan", Typeflag: tar.TypeLink, Linkname: "site/.env"}},
+		{hdr: tar.Header{Name: "site/pipe", Typeflag: tar.TypeFifo, Mode: 0o644}},
+		{hdr: tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0o644}, body: "evil"},
+	}
+	for _, e := range entries {
+		e.hdr.Size = int64(len(e.body))
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UploadArchive(context.Background(), &buf, ArchiveTarGz, "dst")
+	if err == nil {
+		t.Fatal("expected an error for the unsafe entry")
+	}
+	// A hard link to a skipped entry has no remote file to copy
+	if len(result.Failed) != 2 || result.Failed[0].RelPath != "site/orphan" || result.Failed[1].RelPath != "../evil.txt" {
+		t.Errorf("expected site/orphan and ../evil.txt to fail, got %+v", result.Failed)
+	}
+	if len(result.Uploaded) != 4 { // index.html, big.bin, latest.symlink and the hard link
+		t.Errorf("expected 4 uploaded entries, got %+v", result.Uploaded)
+	}
+	if data, _ := fs.file("files/user/dst/site/copy/index.html"); string(data) != "<html/>" {
+		t.Errorf("expected the hard link to be copied from its target, got %q", data)
+	}
+	reasons := make(map[string]string)
+	for _, s := range result.Skipped {
+		reasons[s.RelPath] = s.Reason
+	}
+	if reasons["site/.env"] != "hidden" || reasons["site/pipe"] != "named pipe" {
+		t.Errorf("unexpected skipped entries: %v", reasons)
+	}
+
+	if data, _ := fs.file("files/user/dst/site/assets/big.bin"); string(data) != big {
+		t.Errorf("expected big.bin to be uploaded in chunks, got %d bytes", len(data))
+	}
+	if data, _ := fs.file("files/user/dst/site/latest.symlink"); string(data) != "symlink -> index.html\n" {
+		t.Errorf("unexpected link description %q", data)
+	}
+	if _, ok := fs.file("files/user/evil.txt"); ok {
+		t.Error("unsafe entry escaped the destination directory")
+	}
+	fs.mu.Lock()
+	got := fs.mtimes["files/user/dst/site/index.html"]
+	dirOK := fs.dirs["files/user/dst/site"]
+	fs.mu.Unlock()
+	if !got.Equal(mtime) {
+		t.Errorf("expected mtime %v to be preserved, got %v", mtime, got)
+	}
+	if !dirOK {
+		t.Error("expected the site directory to be created")
+	}
+}
+
+func TestUploadArchive_HardLinkWithSymlinkFollow(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.SymlinkPolicy = SymlinkFollow
+	c.SetConfig(cfg)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []tar.Header{
+		{Name: "a.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5},
+		{Name: "b.txt", Typeflag: tar.TypeLink, Linkname: "a.txt"},
+		{Name: "c.txt", Typeflag: tar.TypeSymlink, Linkname: "a.txt"},
+	} {
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			_, _ = tw.Write([]byte("hello"))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UploadArchive(context.Background(), &buf, ArchiveTar, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Uploaded) != 2 || len(result.Skipped) != 1 || result.Skipped[0].RelPath != "c.txt" {
+		t.Fatalf("expected the hard link to be uploaded and the symlink skipped, got %+v / %+v", result.Uploaded, result.Skipped)
+	}
+	if data, _ := fs.file("files/user/dst/b.txt"); string(data) != "hello" {
+		t.Fatalf("expected the hard link content, got %q", data)
+	}
+}
+
+func TestUploadArchive_Zip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{"docs/a.txt": "alpha", "b.txt": "bravo"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// bytes.Reader is read in place; a plain io.Reader is spooled first
+	for name, r := range map[string]io.Reader{
+		"reader-at": bytes.NewReader(buf.Bytes()),
+		"stream":    io.MultiReader(bytes.NewReader(buf.Bytes())),
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := newFakeNextcloud(t)
+			c := fs.client("user")
+			result, err := c.UploadArchive(context.Background(), r, ArchiveZip, "dst")
+			if err != nil {
+				t.Fatalf("UploadArchive: %v", err)
+			}
+			if len(result.Uploaded) != 2 || result.BytesUploaded != 10 {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if data, _ := fs.file("files/user/dst/docs/a.txt"); string(data) != "alpha" {
+				t.Errorf("unexpected content %q", data)
+			}
+		})
+	}
+}
+
+// countingHashCache wraps the memory cache and counts lookups that missed.
+type countingHashCache struct {
+	HashCache
+	mu     sync.Mutex
+	misses int
+}
+
+func (h *countingHashCache) Get(key HashKey) (string, bool) {
+	sum, ok := h.HashCache.Get(key)
+	if !ok {
+		h.mu.Lock()
+		h.misses++
+		h.mu.Unlock()
+	}
+	return sum, ok
+}
+
+// uploadAndReportSkip uploads localPath and reports whether it was skipped.
+func uploadAndReportSkip(t *testing.T, c *Client, localPath, dstPath string) bool {
+	t.Helper()
+	skipped := false
+	c.config.EventFunc = func(info EventInfo) {
+		if info.Event == EventUploadSkipped {
+			skipped = true
+		}
+	}
+	if err := c.UploadFile(localPath, dstPath); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	return skipped
+}
+
+func TestCompareMode_SizeAndMtime(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"rec.dat": "AAAA"})
+	local := filepath.Join(root, "rec.dat")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(local, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []CompareMode{CompareSize, CompareSizeMtime, CompareAlways} {
+		cfg := DefaultConfig()
+		cfg.CompareMode = mode
+		c.SetConfig(cfg)
+		if uploadAndReportSkip(t, c, local, "rec.dat") {
+			t.Fatalf("mode %d: first upload must not be skipped", mode)
+		}
+		fs.mu.Lock()
+		got := fs.mtimes["files/user/rec.dat"]
+		fs.mu.Unlock()
+		if !got.Equal(old) {
+			t.Errorf("mode %d: expected remote mtime %v, got %v", mode, old, got)
+		}
+
+		unchanged := uploadAndReportSkip(t, c, local, "rec.dat")
+		if unchanged != (mode != CompareAlways) {
+			t.Errorf("mode %d: unchanged file skipped=%v", mode, unchanged)
+		}
+
+		// Same-length edit with a new mtime
+		if err := os.WriteFile(local, []byte("BBBB"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		edited := uploadAndReportSkip(t, c, local, "rec.dat")
+		if edited != (mode == CompareSize) {
+			t.Errorf("mode %d: edited file skipped=%v", mode, edited)
+		}
+		fs.putFile("files/user/rec.dat", nil, time.Time{})
+		if err := os.WriteFile(local, []byte("AAAA"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(local, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetInterceptor_KeepsMoveHeaders(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	local := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(local, bytes.Repeat([]byte("x"), 3*1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(local, old, old); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.CompareMode = CompareChecksum
+	c.SetConfig(cfg)
+
+	var mu sync.Mutex
+	var moves int
+	c.SetInterceptor(func(method string, rq *http.Request) {
+		if method == "MOVE" && rq.Header.Get("OC-Total-Length") != "" {
+			mu.Lock()
+			moves++
+			mu.Unlock()
+		}
+	})
+	if err := c.UploadFile(local, "big.bin"); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if moves != 1 {
+		t.Errorf("expected the interceptor to see 1 MOVE with headers, saw %d", moves)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if got := fs.mtimes["files/user/big.bin"]; !got.Equal(old) {
+		t.Errorf("expected remote mtime %v, got %v", old, got)
+	}
+	if fs.checksums["files/user/big.bin"] == "" {
+		t.Error("expected OC-Checksum to reach the server")
+	}
+}
+
+// tokenAuth is a gowebdav Authenticator sending a bearer token.
+type tokenAuth struct{ token string }
+
+func (a *tokenAuth) Authorize(c *http.Client, rq *http.Request, path string) error {
+	rq.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *tokenAuth) Verify(c *http.Client, rs *http.Response, path string) (bool, error) {
+	if rs.StatusCode == http.StatusUnauthorized {
+		return false, gowebdav.NewPathError("Authorize", path, rs.StatusCode)
+	}
+	return false, nil
+}
+
+func (a *tokenAuth) Clone() gowebdav.Authenticator { return a }
+func (a *tokenAuth) Close() error                  { return nil }
+
+func TestClient_HeadersAndAuthOnChunkRequests(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(local, bytes.Repeat([]byte("x"), 3*1024), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+
+	for _, tc := range []struct {
+		name   string
+		auth   string
+		client func(fs *fakeNextcloud) *Client
+	}{
+		{"negotiated basic", "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")), func(fs *fakeNextcloud) *Client {
+			return fs.client("user")
+		}},
+		{"custom authorizer", "Bearer tok", func(fs *fakeNextcloud) *Client {
+			return NewAuthClient(fs.URL+"/remote.php/dav/", "user", gowebdav.NewPreemptiveAuth(&tokenAuth{token: "tok"}))
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := newFakeNextcloud(t)
+			fs.auth = tc.auth
+			var mu sync.Mutex
+			var tagged int
+			fs.holdPut = func(r *http.Request) {
+				if r.Header.Get("X-Request-Source") == "backup" {
+					mu.Lock()
+					tagged++
+					mu.Unlock()
+				}
+			}
+			c := tc.client(fs)
+			c.SetConfig(cfg)
+			c.SetHeader("X-Request-Source", "backup")
+
+			if err := c.UploadFile(local, "big.bin"); err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			if n := fs.putCount(); n != 3 {
+				t.Fatalf("expected 3 chunk PUTs, got %d", n)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tagged < 3 {
+				t.Errorf("expected the header on every chunk PUT, saw it on %d", tagged)
+			}
+			if data, _ := fs.file("files/user/big.bin"); len(data) != 3*1024 {
+				t.Errorf("expected assembled file of %d bytes, got %d", 3*1024, len(data))
+			}
+		})
+	}
+}
+
+func TestCompareMode_Checksum(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"vm.img": "AAAA"})
+	local := filepath.Join(root, "vm.img")
+	stamp := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(local, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &countingHashCache{HashCache: NewMemoryHashCache()}
+	cfg := DefaultConfig()
+	cfg.CompareMode = CompareChecksum
+	cfg.HashCache = cache
+	c.SetConfig(cfg)
+
+	if uploadAndReportSkip(t, c, local, "vm.img") {
+		t.Fatal("first upload must not be skipped")
+	}
+	fs.mu.Lock()
+	sum := fs.checksums["files/user/vm.img"]
+	fs.mu.Unlock()
+	if sum != "SHA1:e2512172abf8cc9f67fdd49eb6cacf2df71bbad3" {
+		t.Fatalf("expected the upload to send OC-Checksum, got %q", sum)
+	}
+
+	if !uploadAndReportSkip(t, c, local, "vm.img") {
+		t.Error("unchanged file should be skipped by checksum")
+	}
+	if cache.misses != 1 {
+		t.Errorf("expected the file to be hashed once, got %d cache misses", cache.misses)
+	}
+
+	// Same length and mtime, different content: only the checksum notices
+	if err := os.WriteFile(local, []byte("BBBB"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(local, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+	cfg.HashCache = NewMemoryHashCache()
+	if uploadAndReportSkip(t, c, local, "vm.img") {
+		t.Error("edited file should be uploaded")
+	}
+	if data, _ := fs.file("files/user/vm.img"); string(data) != "BBBB" {
+		t.Errorf("unexpected remote content %q", data)
+	}
+}
+
+func TestMemoryHashCache_Eviction(t *testing.T) {
+	cache := NewMemoryHashCache().(*memoryHashCache)
+	cache.maxEntries = 10
+	key := func(i int) HashKey {
+		return HashKey{Path: fmt.Sprintf("/f%d", i), Size: int64(i), Algorithm: "SHA1"}
+	}
+	for i := 0; i < 10; i++ {
+		cache.Put(key(i), strconv.Itoa(i))
+	}
+	if _, ok := cache.Get(key(0)); !ok {
+		t.Fatal("expected the cache to hold its cap")
+	}
+	cache.Put(key(10), "10")
+	if n := len(cache.entries); n != 9 {
+		t.Fatalf("expected eviction down to 90%% of the cap, got %d entries", n)
+	}
+	if sum, ok := cache.Get(key(0)); !ok || sum != "0" {
+		t.Fatal("expected the recently used entry to be kept")
+	}
+	if _, ok := cache.Get(key(1)); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+}
+
+func TestFileHashCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "hashes.db")
+	local := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(local, []byte("AAAA"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := OpenFileHashCache(cachePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient("http://localhost/", "user", "pass")
+	cfg := DefaultConfig()
+	cfg.HashCache = cache
+	c.SetConfig(cfg)
+	sum, err := c.localChecksum(local, "SHA1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hash survives a reopen, even with a torn record at the end
+	f, _ := os.OpenFile(cachePath, os.O_WRONLY|os.O_APPEND, 0o600)
+	f.WriteString(`{"id":"torn`)
+	f.Close()
+	cache, err = OpenFileHashCache(cachePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	info, _ := os.Stat(local)
+	if got, ok := cache.Get(hashKeyFor(local, info, "SHA1")); !ok || got != sum {
+		t.Fatalf("expected cached hash %s after reopen, got %q (%v)", sum, got, ok)
+	}
+
+	// A rename keeps the hash where inodes are available
+	renamed := filepath.Join(dir, "renamed.bin")
+	if err := os.Rename(local, renamed); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = os.Stat(renamed)
+	if key := hashKeyFor(renamed, info, "SHA1"); key.Inode != 0 {
+		if _, ok := cache.Get(key); !ok {
+			t.Error("expected the hash to follow the renamed file")
+		}
+	}
+
+	// An edit invalidates the entry
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(renamed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = os.Stat(renamed)
+	if _, ok := cache.Get(hashKeyFor(renamed, info, "SHA1")); ok {
+		t.Error("expected a miss after the file changed")
+	}
+	if removed, err := cache.Prune(); err != nil || removed != 1 || cache.Len() != 0 {
+		t.Errorf("expected Prune to drop the stale entry, got %d, %v (len %d)", removed, err, cache.Len())
+	}
+
+	// The entry cap evicts down to 90%
+	for i := 0; i < 15; i++ {
+		cache.Put(HashKey{Path: "/f" + strconv.Itoa(i), Size: 1, Algorithm: "SHA1"}, "x")
+	}
+	if n := cache.Len(); n > 10 {
+		t.Errorf("expected at most 10 entries, got %d", n)
+	}
+	cache.Invalidate("/f14")
+	if _, ok := cache.Get(HashKey{Path: "/f14", Size: 1, Algorithm: "SHA1"}); ok {
+		t.Error("expected Invalidate to remove the entry")
+	}
+}
+
+func TestUploadManager_SchedulerConcurrencyAndPriority(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"a": "a", "b": "b", "c": "c", "d": "d"})
+
+	var mu sync.Mutex
+	var order []string
+	inFlight, maxInFlight := 0, 0
+	cfg := DefaultConfig()
+	cfg.EventFunc = func(e EventInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Event {
+		case EventUploadStarted:
+			order = append(order, e.Filename)
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+		case EventUploadComplete, EventUploadFailed:
+			inFlight--
+		}
+	}
+	c.SetConfig(cfg)
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{MaxConcurrent: 1})
+	ids := make(map[string]string)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		opts := SessionOptions{}
+		if name == "b" {
+			opts.Priority = 5
+		}
+		sess, err := manager.AddUploadSessionWithOptions(filepath.Join(root, name), "dst/"+name, c, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = sess.ID
+	}
+
+	// Hold the only slot with a paused session while the others queue up
+	a, _ := manager.GetUploadSession(ids["a"])
+	a.Controller.Pause()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := manager.StartUpload(ids[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.MoveToFront(ids["d"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetPriority(ids["c"], 10); err != nil {
+		t.Fatal(err)
+	}
+	var queued []string
+	for _, sess := range manager.QueuedSessions() {
+		queued = append(queued, filepath.Base(sess.LocalPath))
+	}
+	if strings.Join(queued, "") != "cbd" {
+		t.Fatalf("expected queue order cbd, got %v", queued)
+	}
+
+	a.Controller.Resume()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		done := 0
+		for _, sess := range manager.GetUploadSessions() {
+			if sess.Status == StatusCompleted {
+				done++
+			}
+		}
+		if done == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions did not complete: %+v", manager.GetUploadSessions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(order, "") != "acbd" {
+		t.Errorf("expected start order acbd, got %v", order)
+	}
+	if maxInFlight != 1 {
+		t.Errorf("expected at most 1 upload in flight, got %d", maxInFlight)
+	}
+}
+
+func TestUploadManager_AutoStart(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"a": "a", "b": "b", "c": "c"})
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{MaxConcurrent: 2, AutoStart: true})
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := manager.AddUploadSession(filepath.Join(root, name), "dst/"+name, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fs.putCount() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected all queued sessions to be started automatically, got %d uploads", fs.putCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForStatus polls the manager until the session reaches status.
+func waitForStatus(t *testing.T, manager *UploadManager, id string, status UploadStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sess, err := manager.GetUploadSession(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sess.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session %s: expected status %s, got %s", id, status, sess.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUploadManager_SessionStoreAndRestore(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	content := strings.Repeat("x", 1024) + strings.Repeat("y", 1024) + "z"
+	root := writeTestTree(t, map[string]string{"big.bin": content, "other.bin": "other"})
+
+	store, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions are saved when added and deleted when removed
+	manager := NewUploadManagerWithConfig(ManagerConfig{Store: store})
+	sess, err := manager.AddUploadSessionWithOptions(filepath.Join(root, "other.bin"), "dst/other.bin", c, SessionOptions{Priority: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.Load()
+	if err != nil || len(records) != 1 || records[0].Priority != 3 || records[0].Config.ChunkSize != 1024 {
+		t.Fatalf("expected the added session to be stored, got %+v (%v)", records, err)
+	}
+	if err := manager.RemoveUploadSession(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := store.Load(); len(records) != 0 {
+		t.Fatalf("expected the removed session to be deleted, got %+v", records)
+	}
+
+	// Simulate a process that died mid-upload, after the first chunk
+	fs.putFile("uploads/user/up-1/0", []byte(content[:1024]), time.Now())
+	info, err := os.Stat(filepath.Join(root, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, rec := range []SessionRecord{
+		{
+			ID: "running-1", LocalPath: filepath.Join(root, "big.bin"), RemotePath: "dst/big.bin",
+			BaseURL: c.baseURL, Username: "user", Status: StatusRunning,
+			Config: SessionConfig{ChunkSize: 1024, MaxRetries: 3},
+			Checkpoint: &Checkpoint{
+				UploadID: "up-1", FileSize: int64(len(content)), ModTime: info.ModTime(), ChunkSize: 1024,
+				BytesUploaded: 1024, ChunksUploaded: 1, TotalChunks: 3,
+			},
+			CreatedAt: now, UpdatedAt: now,
+		},
+		{
+			ID: "paused-1", LocalPath: filepath.Join(root, "other.bin"), RemotePath: "dst/other.bin",
+			BaseURL: c.baseURL, Username: "user", Status: StatusPaused,
+			Config: SessionConfig{ChunkSize: 1024}, CreatedAt: now, UpdatedAt: now,
+		},
+	} {
+		if err := store.Save(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restarted := NewUploadManagerWithConfig(ManagerConfig{Store: store})
+	restored, err := restarted.RestoreSessions(func(rec SessionRecord) (*Client, error) {
+		if rec.Username != "user" {
+			return nil, fmt.Errorf("unknown user %s", rec.Username)
+		}
+		return c, nil
+	})
+	if err != nil || len(restored) != 2 {
+		t.Fatalf("expected 2 restored sessions, got %d (%v)", len(restored), err)
+	}
+
+	waitForStatus(t, restarted, "running-1", StatusCompleted)
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("expected the interrupted upload to complete, got %d bytes", len(data))
+	}
+	if fs.putCount() != 2 {
+		t.Errorf("expected only the two remaining chunks to be uploaded, got %d PUTs", fs.putCount())
+	}
+
+	if sess, _ := restarted.GetUploadSession("paused-1"); sess.Status != StatusPaused {
+		t.Fatalf("expected the paused session to stay paused, got %s", sess.Status)
+	}
+	if err := restarted.ResumeUpload("paused-1"); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, restarted, "paused-1", StatusCompleted)
+
+	records, _ = store.Load()
+	for _, rec := range records {
+		if rec.Status != StatusCompleted || rec.Checkpoint != nil {
+			t.Errorf("expected stored session %s to be completed without checkpoint, got %s", rec.ID, rec.Status)
+		}
+	}
+}
+
+// nextEvent reads one event from ch or fails after a timeout.
+func nextEvent(t *testing.T, ch <-chan ManagerEvent) ManagerEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("event channel closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for manager event")
+	}
+	return ManagerEvent{}
+}
+
+func TestUploadManager_Subscribe(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	alice := fs.client("alice")
+	bob := fs.client("bob")
+	root := writeTestTree(t, map[string]string{"a": "aaa", "b": "bbb"})
+
+	manager := NewUploadManager()
+	sessA, err := manager.AddUploadSession(filepath.Join(root, "a"), "dst/a", alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessB, err := manager.AddUploadSession(filepath.Join(root, "b"), "dst/b", bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statusA, unsubA := manager.Subscribe(EventFilter{
+		SessionIDs: []string{sessA.ID},
+		Types:      []ManagerEventType{ManagerEventStatus},
+	})
+	defer unsubA()
+	bobEvents, unsubBob := manager.Subscribe(EventFilter{Client: bob, Overflow: OverflowBlock})
+	defer unsubBob()
+	small, unsubSmall := manager.Subscribe(EventFilter{BufferSize: 1})
+
+	if err := manager.StartUpload(sessA.ID); err != nil {
+		t.Fatal(err)
+	}
+	ev := nextEvent(t, statusA)
+	if ev.SessionID != sessA.ID || ev.PrevStatus != StatusQueued || ev.Status != StatusRunning || ev.Client != alice {
+		t.Fatalf("unexpected first event: %+v", ev)
+	}
+	if ev = nextEvent(t, statusA); ev.Status != StatusCompleted {
+		t.Fatalf("expected completed status event, got %+v", ev)
+	}
+
+	if err := manager.StartUpload(sessB.ID); err != nil {
+		t.Fatal(err)
+	}
+	var sawProgress, sawUpload bool
+	for {
+		ev := nextEvent(t, bobEvents)
+		if ev.SessionID != sessB.ID {
+			t.Fatalf("client filter leaked event of session %s", ev.SessionID)
+		}
+		switch ev.Type {
+		case ManagerEventProgress:
+			sawProgress = ev.Progress != nil
+		case ManagerEventUpload:
+			sawUpload = ev.Upload != nil
+		}
+		if ev.Type == ManagerEventStatus && ev.Status == StatusCompleted {
+			break
+		}
+	}
+	if !sawProgress || !sawUpload {
+		t.Fatalf("expected progress and upload events, got progress=%v upload=%v", sawProgress, sawUpload)
+	}
+
+	// The full subscriber was never read: all but the first event were dropped
+	unsubSmall()
+	n := 0
+	for range small {
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 buffered event with drop policy, got %d", n)
+	}
+	unsubSmall()
+}
+
+func TestUploadManager_SubscribeStalledBlockingSubscriber(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{"big.bin": strings.Repeat("x", 8*1024)})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	stalled, unsubStalled := manager.Subscribe(EventFilter{BufferSize: 1, Overflow: OverflowBlock})
+	other, unsubOther := manager.Subscribe(EventFilter{BufferSize: 1, Overflow: OverflowBlock})
+	defer unsubOther()
+	dropping, unsubDropping := manager.Subscribe(EventFilter{BufferSize: 4})
+	defer unsubDropping()
+
+	var received []ManagerEvent
+	otherDone := make(chan struct{})
+	go func() {
+		defer close(otherDone)
+		for ev := range other {
+			received = append(received, ev)
+		}
+	}()
+
+	// Neither the manager nor the other subscribers wait for the stalled one
+	sess, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	if err := manager.StartUpload(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, sess.ID); err != nil {
+		t.Fatalf("expected the upload to complete despite a stalled subscriber, got %v", err)
+	}
+	if len(dropping) > cap(dropping) || len(dropping) == 0 {
+		t.Fatalf("expected the dropping subscriber to hold at most %d events, got %d", cap(dropping), len(dropping))
+	}
+
+	// The stalled subscriber still gets every event, in order
+	var stalledEvents []ManagerEvent
+	for {
+		ev := nextEvent(t, stalled)
+		stalledEvents = append(stalledEvents, ev)
+		if ev.Type == ManagerEventStatus && ev.Status == StatusCompleted {
+			break
+		}
+	}
+	unsubOther()
+	<-otherDone
+	if len(stalledEvents) != len(received) {
+		t.Fatalf("expected both blocking subscribers to get %d events, got %d", len(received), len(stalledEvents))
+	}
+	for i := range received {
+		if received[i].Type != stalledEvents[i].Type || !received[i].Time.Equal(stalledEvents[i].Time) {
+			t.Fatalf("event %d differs between subscribers: %+v vs %+v", i, received[i], stalledEvents[i])
+		}
+	}
+
+	unsubStalled()
+	for range stalled {
+	}
+}
+
+func TestUploadManager_CancelWaitAndErr(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{
+		"big.bin":    strings.Repeat("x", 3000),
+		"queued.bin": "queued",
+		"ok.bin":     "ok",
+		"bad.bin":    "bad",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	manager := NewUploadManager()
+
+	// A paused upload is woken by CancelUpload and its chunks are removed
+	big, err := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := manager.Subscribe(EventFilter{SessionIDs: []string{big.ID}, Types: []ManagerEventType{ManagerEventUpload}})
+	defer unsubscribe()
+	big.Controller.Pause()
+	if err := manager.StartUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	for ev := nextEvent(t, events); ev.Upload.Event != EventUploadPaused; ev = nextEvent(t, events) {
+	}
+	if err := manager.CancelUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, big.ID); !errors.Is(err, ErrUploadCancelled) {
+		t.Fatalf("expected ErrUploadCancelled from Wait, got %v", err)
+	}
+	sess, _ := manager.GetUploadSession(big.ID)
+	if sess.Status != StatusCancelled || !errors.Is(sess.Err, ErrUploadCancelled) {
+		t.Fatalf("expected cancelled session, got %s (%v)", sess.Status, sess.Err)
+	}
+	fs.mu.Lock()
+	for name := range fs.dirs {
+		if strings.HasPrefix(name, "uploads/user/") {
+			t.Errorf("upload collection %s left on the server", name)
+		}
+	}
+	fs.mu.Unlock()
+	if err := manager.CancelUpload(big.ID); err == nil {
+		t.Fatal("expected error cancelling a finished session")
+	}
+
+	// Cancelling a queued session removes chunks recorded in its checkpoint
+	queued, err := manager.AddUploadSession(filepath.Join(root, "queued.bin"), "dst/queued.bin", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.putFile("uploads/user/up-9/0", []byte("partial"), time.Now())
+	manager.mu.Lock()
+	manager.sessions[queued.ID].Checkpoint = &Checkpoint{UploadID: "up-9"}
+	manager.mu.Unlock()
+	if err := manager.CancelUpload(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fs.file("uploads/user/up-9/0"); ok {
+		t.Error("expected queued session's chunks to be removed")
+	}
+	select {
+	case <-queued.Done():
+	default:
+		t.Fatal("expected Done to be closed for a cancelled queued session")
+	}
+
+	// Completed and failed sessions report through Wait and Err
+	ok, _ := manager.AddUploadSession(filepath.Join(root, "ok.bin"), "dst/ok.bin", c)
+	if err := manager.StartUpload(ok.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, ok.ID); err != nil {
+		t.Fatalf("expected completed upload, got %v", err)
+	}
+
+	fs.mu.Lock()
+	fs.failPut = func(p string, data []byte) bool { return string(data) == "bad" }
+	fs.mu.Unlock()
+	bad, _ := manager.AddUploadSession(filepath.Join(root, "bad.bin"), "dst/bad.bin", c)
+	if err := manager.StartUpload(bad.ID); err != nil {
+		t.Fatal(err)
+	}
+	var uploadErr *UploadError
+	if err := manager.Wait(ctx, bad.ID); !errors.As(err, &uploadErr) {
+		t.Fatalf("expected *UploadError from Wait, got %v", err)
+	}
+	if sess, _ := manager.GetUploadSession(bad.ID); sess.Status != StatusFailed || sess.Err == nil {
+		t.Fatalf("expected failed session with Err, got %s (%v)", sess.Status, sess.Err)
+	}
+}
+
+func TestUploadManager_SessionStatsAndSummary(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{"big.bin": strings.Repeat("x", 3000), "bad.bin": "bad"})
+	fs.failPut = func(p string, data []byte) bool { return string(data) == "bad" }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	big, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	bad, _ := manager.AddUploadSession(filepath.Join(root, "bad.bin"), "dst/bad.bin", c)
+	if big.Stats.BytesTotal != 3000 {
+		t.Fatalf("expected total known before start, got %d", big.Stats.BytesTotal)
+	}
+	if s := manager.Summary(); s.Sessions != 2 || s.ByStatus[StatusQueued] != 2 || s.BytesTotal != 3003 || s.BytesSent != 0 {
+		t.Fatalf("unexpected summary before start: %+v", s)
+	}
+
+	for _, id := range []string{big.ID, bad.ID} {
+		if err := manager.StartUpload(id); err != nil {
+			t.Fatal(err)
+		}
+		_ = manager.Wait(ctx, id)
+	}
+
+	sess, _ := manager.GetUploadSession(big.ID)
+	st := sess.Stats
+	if st.BytesSent != 3000 || st.ChunksDone != 3 || st.ChunksTotal != 3 || st.Percentage != 100 || st.Attempts != 1 {
+		t.Fatalf("unexpected stats for completed session: %+v", st)
+	}
+	if st.AverageSpeed <= 0 || st.CurrentSpeed != 0 || st.ETA != 0 || st.LastError != nil {
+		t.Fatalf("unexpected throughput stats for completed session: %+v", st)
+	}
+
+	sess, _ = manager.GetUploadSession(bad.ID)
+	if sess.Stats.Attempts != 1 || sess.Stats.LastError == nil || sess.Stats.BytesSent != 0 {
+		t.Fatalf("unexpected stats for failed session: %+v", sess.Stats)
+	}
+
+	s := manager.Summary()
+	if s.ByStatus[StatusCompleted] != 1 || s.ByStatus[StatusFailed] != 1 || s.Active != 0 {
+		t.Fatalf("unexpected summary counts: %+v", s)
+	}
+	if s.BytesSent != 3000 || s.BytesTotal != 3000 || s.Percentage != 100 || s.Speed != 0 || s.ETA != 0 {
+		t.Fatalf("unexpected summary totals: %+v", s)
+	}
+}
+
+func TestUploadManager_RetryPolicy(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.MaxRetries = 0
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{
+		"big.bin":   strings.Repeat("x", 12*1024),
+		"bad.bin":   "bad",
+		"fatal.bin": "fatal",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var failedOnce bool
+	fs.failPut = func(p string, data []byte) bool {
+		if strings.HasSuffix(p, "/"+strconv.Itoa(11*1024)) && !failedOnce {
+			failedOnce = true
+			return true
+		}
+		return string(data) == "bad" || string(data) == "fatal"
+	}
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+	})
+	events, unsubscribe := manager.Subscribe(EventFilter{Types: []ManagerEventType{ManagerEventRetry}, BufferSize: 16})
+	defer unsubscribe()
+
+	// The second attempt resumes from the checkpoint saved when chunk 12 failed
+	big, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	if err := manager.StartUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, big.ID); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	if ev := nextEvent(t, events); ev.SessionID != big.ID || ev.Attempt != 1 || ev.Err == nil || ev.RetryAt.IsZero() {
+		t.Fatalf("unexpected retry event: %+v", ev)
+	}
+	if n := fs.putCount(); n != 12 {
+		t.Fatalf("expected 11 chunks then 1 resumed chunk, got %d PUTs", n)
+	}
+	sess, _ := manager.GetUploadSession(big.ID)
+	if sess.Stats.Attempts != 2 || sess.Stats.LastError == nil || !sess.NextRetry.IsZero() {
+		t.Fatalf("unexpected session after retry: attempts=%d lastErr=%v next=%v", sess.Stats.Attempts, sess.Stats.LastError, sess.NextRetry)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); len(data) != 12*1024 {
+		t.Fatalf("expected assembled file of %d bytes, got %d", 12*1024, len(data))
+	}
+
+	// Attempts are bounded by MaxAttempts
+	bad, _ := manager.AddUploadSession(filepath.Join(root, "bad.bin"), "dst/bad.bin", c)
+	if err := manager.StartUpload(bad.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, bad.ID); err == nil {
+		t.Fatal("expected the session to fail")
+	}
+	if sess, _ := manager.GetUploadSession(bad.ID); sess.Status != StatusFailed || sess.Stats.Attempts != 3 {
+		t.Fatalf("expected failure after 3 attempts, got %s after %d", sess.Status, sess.Stats.Attempts)
+	}
+
+	// Errors rejected by Retryable fail immediately
+	fatal, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "fatal.bin"), "dst/fatal.bin", c, SessionOptions{
+		Retry: &RetryPolicy{MaxAttempts: 3, Retryable: func(error) bool { return false }},
+	})
+	if err := manager.StartUpload(fatal.ID); err != nil {
+		t.Fatal(err)
+	}
+	_ = manager.Wait(ctx, fatal.ID)
+	if sess, _ := manager.GetUploadSession(fatal.ID); sess.Status != StatusFailed || sess.Stats.Attempts != 1 {
+		t.Fatalf("expected failure after 1 attempt, got %s after %d", sess.Status, sess.Stats.Attempts)
+	}
+}
+
+func TestUploadManager_RetryAfterPause(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	cfg.MaxRetries = 0
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{"big.bin": strings.Repeat("x", 4*1024)})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reached, release := make(chan struct{}), make(chan struct{})
+	var held, armed, failed atomic.Bool
+	fs.holdPut = func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/1024") && held.CompareAndSwap(false, true) {
+			close(reached)
+			<-release
+		}
+	}
+	fs.failPut = func(p string, data []byte) bool {
+		return armed.Load() && strings.HasSuffix(p, "/2048") && failed.CompareAndSwap(false, true)
+	}
+
+	manager := NewUploadManager()
+	sess, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "big.bin"), "dst/big.bin", c, SessionOptions{
+		Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond},
+	})
+	if err := manager.StartUpload(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	<-reached
+	manager.PauseClient(c)
+	close(release)
+	waitForStatus(t, manager, sess.ID, StatusPaused)
+	for manager.Summary().Active > 0 {
+		time.Sleep(5 * time.Millisecond) // Wait for the upload to stop at its checkpoint
+	}
+
+	// Relaunching after the pause is not a failed attempt, so a transient
+	// failure after it is still retried
+	armed.Store(true)
+	manager.ResumeClient(c)
+	if err := manager.Wait(ctx, sess.ID); err != nil {
+		t.Fatalf("expected the retry to succeed, got %v", err)
+	}
+	got, _ := manager.GetUploadSession(sess.ID)
+	if got.Stats.Failures != 1 || got.Stats.Attempts != 3 {
+		t.Fatalf("expected 1 failure in 3 attempts, got %d in %d", got.Stats.Failures, got.Stats.Attempts)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); len(data) != 4*1024 {
+		t.Fatalf("expected assembled file of %d bytes, got %d", 4*1024, len(data))
+	}
+}
+
+func TestUploadManager_SessionGroups(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.SkipHidden = true
+	c.SetConfig(cfg)
+	root := writeTestTree(t, map[string]string{
+		"a.txt":        "a",
+		"sub/b.txt":    "bb",
+		"sub/deep/c":   "ccc",
+		".hidden/skip": "x",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	group, err := manager.AddDirectorySession(root, "dst", c, SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.SessionIDs) != 3 || group.Status != StatusQueued || group.Progress.BytesTotal != 6 {
+		t.Fatalf("unexpected new group: %+v", group)
+	}
+	events, unsubscribe := manager.Subscribe(EventFilter{GroupIDs: []string{group.ID}, Types: []ManagerEventType{ManagerEventGroupComplete}})
+	defer unsubscribe()
+
+	if err := manager.StartGroup(group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.WaitGroup(ctx, group.ID); err != nil {
+		t.Fatalf("expected group to complete, got %v", err)
+	}
+	if ev := nextEvent(t, events); ev.GroupID != group.ID || ev.Status != StatusCompleted {
+		t.Fatalf("unexpected group event: %+v", ev)
+	}
+	for name, want := range map[string]string{"a.txt": "a", "sub/b.txt": "bb", "sub/deep/c": "ccc"} {
+		if data, ok := fs.file("files/user/dst/" + name); !ok || string(data) != want {
+			t.Errorf("%s: got %q (exists=%v)", name, data, ok)
+		}
+	}
+	got, err := manager.GetGroup(group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusCompleted || got.Progress.ByStatus[StatusCompleted] != 3 || got.Progress.Percentage != 100 {
+		t.Fatalf("unexpected finished group: %+v", got)
+	}
+
+	// Cancelling a batch finishes the group as cancelled
+	batch, err := manager.AddBatch([]BatchItem{
+		{LocalPath: filepath.Join(root, "a.txt"), RemotePath: "batch/a.txt"},
+		{LocalPath: filepath.Join(root, "sub/b.txt"), RemotePath: "batch/b.txt"},
+	}, c, SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CancelGroup(batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-batch.Done():
+	case <-ctx.Done():
+		t.Fatal("expected cancelled batch to finish")
+	}
+	if got, _ := manager.GetGroup(batch.ID); got.Status != StatusCancelled {
+		t.Fatalf("expected cancelled batch, got %s", got.Status)
+	}
+	if err := manager.RemoveGroup(batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.GetUploadSessions()) != 3 {
+		t.Fatalf("expected batch sessions to be removed, got %d sessions", len(manager.GetUploadSessions()))
+	}
+}
+
+// windowAround returns a daily window from start to end (both today).
+func windowAround(start, end time.Time) TimeWindow {
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	return TimeWindow{Start: start.Sub(midnight), End: end.Sub(midnight), Location: time.Local}
+}
+
+func TestUploadManager_ScheduleWindows(t *testing.T) {
+	now := time.Now()
+	if tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local); tomorrow.Sub(now) < 10*time.Second {
+		t.Skip("too close to midnight for daily windows")
+	}
+
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	content := strings.Repeat("w", 12*1024)
+	root := writeTestTree(t, map[string]string{"big.bin": content, "later.bin": "later"})
+	fs.failPut = func(p string, data []byte) bool {
+		time.Sleep(20 * time.Millisecond) // Keep the upload running across the window change
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	statuses, unsubscribe := manager.Subscribe(EventFilter{Types: []ManagerEventType{ManagerEventStatus}, BufferSize: 64})
+	defer unsubscribe()
+
+	// A not-before time keeps the session queued
+	later, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "later.bin"), "dst/later.bin", c, SessionOptions{
+		Schedule: &UploadSchedule{NotBefore: time.Now().Add(300 * time.Millisecond)},
+	})
+	if err := manager.StartUpload(later.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sess, _ := manager.GetUploadSession(later.ID); sess.Status != StatusQueued {
+		t.Fatalf("expected session to wait for its start time, got %s", sess.Status)
+	}
+	if err := manager.Wait(ctx, later.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The window closes mid-upload and reopens later
+	start := time.Now()
+	big, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "big.bin"), "dst/big.bin", c, SessionOptions{
+		Schedule: &UploadSchedule{Windows: []TimeWindow{
+			windowAround(start.Add(-time.Second), start.Add(100*time.Millisecond)),
+			windowAround(start.Add(500*time.Millisecond), start.Add(time.Hour)),
+		}},
+	})
+	putsBefore := fs.putCount()
+	if err := manager.StartUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("expected the upload to wait for the window to reopen, finished after %s", elapsed)
+	}
+	if n := fs.putCount() - putsBefore; n != 12 {
+		t.Fatalf("expected the upload to resume from its checkpoint (12 chunks), got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+
+	var paused bool
+	for len(statuses) > 0 {
+		if ev := <-statuses; ev.SessionID == big.ID && ev.Status == StatusPaused {
+			paused = true
+		}
+	}
+	if !paused {
+		t.Fatal("expected the session to be paused when its window closed")
+	}
+}
+
+func TestUploadManager_SetScheduleCopies(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"f.bin": "data"})
+	manager := NewUploadManager()
+	sess, _ := manager.AddUploadSession(filepath.Join(root, "f.bin"), "dst/f.bin", c)
+
+	notBefore := time.Now().Add(time.Hour)
+	schedule := &UploadSchedule{
+		NotBefore: notBefore,
+		Windows:   []TimeWindow{{Days: []time.Weekday{time.Monday}, Start: time.Hour, End: 2 * time.Hour}},
+	}
+	if err := manager.SetSchedule(sess.ID, schedule); err != nil {
+		t.Fatal(err)
+	}
+	schedule.NotBefore = time.Time{}
+	schedule.Windows[0].Days[0] = time.Sunday
+	schedule.Windows[0].Start = 0
+	schedule.Windows = append(schedule.Windows, TimeWindow{})
+
+	got, _ := manager.GetUploadSession(sess.ID)
+	if got.Schedule == schedule || !got.Schedule.NotBefore.Equal(notBefore) || len(got.Schedule.Windows) != 1 {
+		t.Fatalf("expected the session to keep its own schedule, got %+v", got.Schedule)
+	}
+	if w := got.Schedule.Windows[0]; w.Days[0] != time.Monday || w.Start != time.Hour {
+		t.Fatalf("expected the window to be unchanged, got %+v", w)
+	}
+}
+
+func TestTimeWindow_WeekdayNights(t *testing.T) {
+	w := TimeWindow{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    20 * time.Hour,
+		End:      6 * time.Hour,
+		Location: time.UTC,
+	}
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC) } // Oct 16, 2026 is a Friday
+	for _, tc := range []struct {
+		t    time.Time
+		open bool
+	}{
+		{at(16, 19), false},
+		{at(16, 23), true},
+		{at(17, 5), true}, // Saturday morning, opened on Friday
+		{at(17, 6), false},
+		{at(17, 21), false},
+		{at(19, 5), false}, // Monday morning, Sunday has no window
+		{at(19, 21), true},
+	} {
+		if got := w.contains(tc.t); got != tc.open {
+			t.Errorf("%s: expected open=%v", tc.t.Format(time.RFC1123), tc.open)
+		}
+	}
+	if next := w.nextChange(at(17, 7)); !next.Equal(at(19, 20)) {
+		t.Errorf("expected next change on Monday 20:00, got %s", next)
+	}
+	s := &UploadSchedule{NotBefore: at(19, 22), Windows: []TimeWindow{w}}
+	if s.allows(at(19, 21)) || !s.allows(at(19, 22)) {
+		t.Error("expected NotBefore to delay the window")
+	}
+}
+
+func TestUploadManager_OwnerFairness(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"a1": "a1", "a2": "a2", "a3": "a3", "b1": "b1", "b2": "b2"})
+	fs.failPut = func(p string, data []byte) bool {
+		time.Sleep(20 * time.Millisecond) // Let the queue build up behind the first upload
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{MaxConcurrent: 1, Fairness: FairnessRoundRobin})
+	statuses, unsubscribe := manager.Subscribe(EventFilter{Types: []ManagerEventType{ManagerEventStatus}, BufferSize: 64})
+	defer unsubscribe()
+
+	// Alice queues all her files before Bob
+	owners := map[string]string{}
+	var ids []string
+	for _, name := range []string{"a1", "a2", "a3", "b1", "b2"} {
+		owner := "alice"
+		if name[0] == 'b' {
+			owner = "bob"
+		}
+		sess, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, name), "dst/"+name, c, SessionOptions{Owner: owner})
+		owners[sess.ID] = name
+		ids = append(ids, sess.ID)
+	}
+	for _, id := range ids {
+		if err := manager.StartUpload(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range ids {
+		if err := manager.Wait(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var order []string
+	for len(statuses) > 0 {
+		if ev := <-statuses; ev.Status == StatusRunning {
+			order = append(order, owners[ev.SessionID])
+		}
+	}
+	if got := strings.Join(order, ","); got != "a1,b1,a2,b2,a3" {
+		t.Fatalf("expected owners to take turns, got start order %s", got)
+	}
+	if usage := manager.OwnerUsage("bob"); usage.BytesToday != 4 || usage.QuotaRemaining != -1 || usage.Active != 0 {
+		t.Fatalf("unexpected usage for bob: %+v", usage)
+	}
+}
+
+func TestUploadManager_OwnerQuotaAndRate(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	content := strings.Repeat("q", 8*1024)
+	root := writeTestTree(t, map[string]string{"big.bin": content})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	manager.SetOwnerLimits("carol", OwnerLimits{DailyQuota: 3 * 1024})
+	sess, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "big.bin"), "dst/big.bin", c, SessionOptions{Owner: "carol"})
+	if err := manager.StartUpload(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The quota pauses the upload with a checkpoint once it is used up
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		s, _ := manager.GetUploadSession(sess.ID)
+		if s.Status == StatusPaused && s.PausedByQuota && manager.OwnerUsage("carol").Active == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the session to be paused by its quota, got %s", s.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if usage := manager.OwnerUsage("carol"); usage.QuotaRemaining != 0 || usage.BytesToday < 3*1024 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	// Raising the quota resumes the upload, paced by the owner's byte rate
+	start := time.Now()
+	manager.SetOwnerLimits("carol", OwnerLimits{DailyQuota: 1 << 20, BytesPerSecond: 10 * 1024})
+	if err := manager.Wait(ctx, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("expected the byte rate to slow the upload, finished after %s", elapsed)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+}
+
+func TestUploadManager_RetentionAndRemoveSessions(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"a": "a", "b": "b", "c": "c", "bad": "bad", "queued": "queued"})
+	fs.failPut = func(p string, data []byte) bool { return string(data) == "bad" }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{
+		Retention: RetentionPolicy{
+			MaxCount: map[UploadStatus]int{StatusCompleted: 1},
+			Interval: 20 * time.Millisecond,
+		},
+	})
+	removedEvents, unsubscribe := manager.Subscribe(EventFilter{Types: []ManagerEventType{ManagerEventRemoved}, BufferSize: 16})
+	defer unsubscribe()
+
+	var ids []string
+	for _, name := range []string{"a", "b", "c", "bad"} {
+		sess, _ := manager.AddUploadSession(filepath.Join(root, name), "dst/"+name, c)
+		if err := manager.StartUpload(sess.ID); err != nil {
+			t.Fatal(err)
+		}
+		_ = manager.Wait(ctx, sess.ID)
+		ids = append(ids, sess.ID)
+	}
+	queued, _ := manager.AddUploadSession(filepath.Join(root, "queued"), "dst/queued", c)
+
+	// The janitor keeps only the newest completed session
+	removed := map[string]bool{nextEvent(t, removedEvents).SessionID: true, nextEvent(t, removedEvents).SessionID: true}
+	if !removed[ids[0]] || !removed[ids[1]] {
+		t.Fatalf("expected the two oldest completed sessions to be pruned, got %v", removed)
+	}
+	if _, err := manager.GetUploadSession(ids[2]); err != nil {
+		t.Fatalf("expected the newest completed session to be kept: %v", err)
+	}
+	if _, err := manager.GetUploadSession(ids[3]); err != nil {
+		t.Fatalf("expected failed sessions to be kept without a limit: %v", err)
+	}
+
+	// Bulk removal by status
+	got, err := manager.RemoveSessions(SessionFilter{Statuses: []UploadStatus{StatusFailed, StatusQueued}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !slices.Contains(got, ids[3]) || !slices.Contains(got, queued.ID) {
+		t.Fatalf("unexpected removed sessions %v", got)
+	}
+	if ev := nextEvent(t, removedEvents); ev.SessionID != ids[3] || ev.Status != StatusFailed {
+		t.Fatalf("unexpected removal event %+v", ev)
+	}
+	if n := len(manager.GetUploadSessions()); n != 1 {
+		t.Fatalf("expected 1 session left, got %d", n)
+	}
+}
+
+func TestUploadManager_Shutdown(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	content := strings.Repeat("s", 12*1024)
+	root := writeTestTree(t, map[string]string{"big.bin": content, "queued.bin": "queued"})
+	fs.failPut = func(p string, data []byte) bool {
+		time.Sleep(20 * time.Millisecond) // Keep the upload running until Shutdown
+		return false
+	}
+	store, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{Store: store, MaxConcurrent: 1})
+	big, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	queued, _ := manager.AddUploadSession(filepath.Join(root, "queued.bin"), "dst/queued.bin", c)
+	for _, id := range []string{big.ID, queued.ID} {
+		if err := manager.StartUpload(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for fs.putCount() < 2 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	summary, err := manager.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Stopped) != 1 || summary.Stopped[0] != big.ID || len(summary.Forced) != 0 {
+		t.Fatalf("unexpected stopped sessions: %+v", summary)
+	}
+	if len(summary.Pending) != 2 || summary.Pending[0].ID != big.ID || summary.Pending[0].Checkpoint == nil {
+		t.Fatalf("expected both sessions pending, the stopped one with a checkpoint: %+v", summary.Pending)
+	}
+	for _, sess := range summary.Pending {
+		if sess.Status != StatusQueued {
+			t.Fatalf("expected session %s to be queued for a restart, got %s", sess.ID, sess.Status)
+		}
+	}
+	if _, err := manager.AddUploadSession(filepath.Join(root, "queued.bin"), "dst/x", c); !errors.Is(err, ErrManagerShutdown) {
+		t.Fatalf("expected ErrManagerShutdown, got %v", err)
+	}
+	sent := summary.Pending[0].Checkpoint.ChunksUploaded
+
+	// The next process continues from the checkpoint
+	putsBefore := fs.putCount()
+	restarted := NewUploadManagerWithConfig(ManagerConfig{Store: store})
+	if _, err := restarted.RestoreSessions(func(SessionRecord) (*Client, error) { return c, nil }); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{big.ID, queued.ID} {
+		if err := restarted.Wait(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fs.putCount() - putsBefore; n != 12-sent+1 {
+		t.Fatalf("expected %d remaining chunks plus the queued file, got %d PUTs", 12-sent, n)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+}
+
+func TestUploadManager_ShutdownFinishesChunkInFlight(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	cfg := DefaultConfig()
+	cfg.ChunkSize = 1024
+	c.SetConfig(cfg)
+	content := strings.Repeat("f", 4*1024)
+	root := writeTestTree(t, map[string]string{"big.bin": content, "stuck.bin": content})
+	store, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The third chunk is held until Shutdown has been called, or until the
+	// request is aborted once stuck is set
+	var hold, stuck atomic.Bool
+	hold.Store(true)
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	aborted := make(chan struct{}, 1)
+	fs.holdPut = func(r *http.Request) {
+		if hold.Load() && strings.HasSuffix(r.URL.Path, "/2048") {
+			started <- struct{}{}
+			var released <-chan struct{}
+			if stuck.Load() {
+				_, _ = io.ReadAll(r.Body) // Lets the server notice the client going away
+			} else {
+				released = release
+			}
+			select {
+			case <-released:
+			case <-r.Context().Done():
+				aborted <- struct{}{}
+			}
+		}
+	}
+
+	manager := NewUploadManagerWithConfig(ManagerConfig{Store: store})
+	big, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", c)
+	if err := manager.StartUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	type result struct {
+		summary ShutdownSummary
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		summary, err := manager.Shutdown(ctx)
+		done <- result{summary, err}
+	}()
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case <-aborted:
+		t.Fatal("expected Shutdown to let the chunk in flight finish")
+	case <-done:
+		t.Fatal("expected Shutdown to wait for the chunk in flight")
+	default:
+	}
+	hold.Store(false)
+	close(release)
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if len(res.summary.Stopped) != 1 || len(res.summary.Forced) != 0 {
+		t.Fatalf("unexpected summary: %+v", res.summary)
+	}
+	if cp := res.summary.Pending[0].Checkpoint; cp == nil || cp.ChunksUploaded != 3 {
+		t.Fatalf("expected a checkpoint including the chunk in flight, got %+v", cp)
+	}
+
+	// The next process only sends the last chunk
+	putsBefore := fs.putCount()
+	restarted := NewUploadManagerWithConfig(ManagerConfig{Store: store})
+	if _, err := restarted.RestoreSessions(func(SessionRecord) (*Client, error) { return c, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Wait(ctx, big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.putCount() - putsBefore; n != 1 {
+		t.Fatalf("expected only the last chunk to be sent, got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/user/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+
+	// A chunk still in flight when ctx expires is aborted
+	hold.Store(true)
+	stuck.Store(true)
+	stuckSess, _ := restarted.AddUploadSession(filepath.Join(root, "stuck.bin"), "dst/stuck.bin", c)
+	if err := restarted.StartUpload(stuckSess.ID); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	summary, err := restarted.Shutdown(shortCtx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to expire, got %v", err)
+	}
+	if len(summary.Forced) != 1 || summary.Forced[0] != stuckSess.ID {
+		t.Fatalf("expected the stuck session to be forced, got %+v", summary)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the chunk in flight to be aborted")
+	}
+}
+
+func TestUploadManager_DependenciesAndHooks(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	c := fs.client("user")
+	root := writeTestTree(t, map[string]string{"data.bin": "data", "manifest.json": "{}", "bad.bin": "bad", "index.json": "[]"})
+	fs.failPut = func(p string, data []byte) bool {
+		time.Sleep(10 * time.Millisecond)
+		return string(data) == "bad"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	manager := NewUploadManager()
+	if _, err := manager.AddUploadSessionWithOptions(filepath.Join(root, "manifest.json"), "dst/m", c, SessionOptions{DependsOn: []string{"missing"}}); err == nil {
+		t.Fatal("expected an unknown dependency to be rejected")
+	}
+
+	// The manifest waits for the data, then is moved into place and shared
+	data, _ := manager.AddUploadSession(filepath.Join(root, "data.bin"), "dst/data.bin", c)
+	var seen CompletionInfo
+	manifest, err := manager.AddUploadSessionWithOptions(filepath.Join(root, "manifest.json"), "dst/.manifest.part", c, SessionOptions{
+		DependsOn: []string{data.ID},
+		Hooks: []CompletionHook{
+			MoveHook("final/manifest.json"),
+			ShareHook(ShareOptions{}),
+			func(ctx context.Context, c *Client, info *CompletionInfo) error {
+				seen = *info
+				return nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StartUpload(manifest.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if sess, _ := manager.GetUploadSession(manifest.ID); sess.Status != StatusQueued {
+		t.Fatalf("expected the manifest to wait for its dependency, got %s", sess.Status)
+	}
+	if err := manager.StartUpload(data.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Wait(ctx, manifest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fs.file("files/user/final/manifest.json"); !ok {
+		t.Fatal("expected the manifest to be moved into place")
+	}
+	if _, ok := fs.file("files/user/dst/.manifest.part"); ok {
+		t.Fatal("expected the temporary manifest to be gone")
+	}
+	if sess, _ := manager.GetUploadSession(manifest.ID); sess.FinalPath != "final/manifest.json" {
+		t.Fatalf("unexpected final path %q", sess.FinalPath)
+	}
+	if seen.RemotePath != "final/manifest.json" || len(seen.Shares) != 1 || seen.Shares[0].URL != fs.URL+"/s/tok1" {
+		t.Fatalf("unexpected completion info %+v", seen)
+	}
+	if len(fs.shares) != 1 || fs.shares[0] != "/final/manifest.json" {
+		t.Fatalf("unexpected shares %v", fs.shares)
+	}
+
+	// A failed dependency cancels its dependents, transitively
+	bad, _ := manager.AddUploadSession(filepath.Join(root, "bad.bin"), "dst/bad.bin", c)
+	second, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "manifest.json"), "dst/m2", c, SessionOptions{DependsOn: []string{bad.ID}})
+	third, _ := manager.AddUploadSessionWithOptions(filepath.Join(root, "index.json"), "dst/i", c, SessionOptions{DependsOn: []string{second.ID}})
+	for _, id := range []string{third.ID, second.ID, bad.ID} {
+		if err := manager.StartUpload(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{second.ID, third.ID} {
+		if err := manager.Wait(ctx, id); !errors.Is(err, ErrDependencyFailed) {
+			t.Fatalf("expected ErrDependencyFailed for %s, got %v", id, err)
+		}
+		if sess, _ := manager.GetUploadSession(id); sess.Status != StatusCancelled || sess.Stats.Attempts != 0 {
+			t.Fatalf("expected %s to be cancelled without starting, got %s", id, sess.Status)
+		}
+	}
+}
+
+func TestUploadManager_PauseGate(t *testing.T) {
+	fs := newFakeNextcloud(t)
+	alice, bob := fs.client("alice"), fs.client("bob")
+	for _, c := range []*Client{alice, bob} {
+		cfg := DefaultConfig()
+		cfg.ChunkSize = 1024
+		c.SetConfig(cfg)
+	}
+	content := strings.Repeat("g", 8*1024)
+	root := writeTestTree(t, map[string]string{"big.bin": content, "small.bin": "small", "new.bin": "new"})
+	fs.failPut = func(p string, data []byte) bool {
+		time.Sleep(20 * time.Millisecond) // Keep the upload running across the pause
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A global pause holds new starts until it is lifted
+	manager := NewUploadManagerWithConfig(ManagerConfig{MaxConcurrent: 1})
+	manager.PauseAllUploads()
+	held, _ := manager.AddUploadSession(filepath.Join(root, "new.bin"), "dst/new.bin", bob)
+	if err := manager.StartUpload(held.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if sess, _ := manager.GetUploadSession(held.ID); sess.Status != StatusQueued {
+		t.Fatalf("expected the session to stay queued while paused, got %s", sess.Status)
+	}
+	manager.ResumeAllUploads()
+	if err := manager.Wait(ctx, held.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pausing a client releases its slot to other clients
+	big, _ := manager.AddUploadSession(filepath.Join(root, "big.bin"), "dst/big.bin", alice)
+	small, _ := manager.AddUploadSession(filepath.Join(root, "small.bin"), "dst/small.bin", bob)
+	for _, id := range []string{big.ID, small.ID} {
+		if err := manager.StartUpload(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for fs.putCount() < 3 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	manager.PauseClient(alice)
+	if err := manager.Wait(ctx, small.ID); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := manager.GetUploadSession(big.ID)
+	if paused, _ := manager.IsPaused(big.ID); sess.Status != StatusPaused || !paused || sess.Checkpoint == nil {
+		t.Fatalf("expected the client's session to be paused with a checkpoint, got %s", sess.Status)
+	}
+	if err := manager.ResumeUpload(big.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if sess, _ := manager.GetUploadSession(big.ID); sess.Status != StatusQueued {
+		t.Fatalf("expected the resumed session to wait for its client, got %s", sess.Status)
+	}
+
+	putsBefore := fs.putCount()
+	manager.ResumeClient(alice)
+	if err := manager.Wait(ctx, big.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := fs.putCount() - putsBefore; n != 8-sess.Checkpoint.ChunksUploaded {
+		t.Fatalf("expected the upload to continue from its checkpoint, got %d PUTs", n)
+	}
+	if data, _ := fs.file("files/alice/dst/big.bin"); string(data) != content {
+		t.Fatalf("unexpected uploaded content (%d bytes)", len(data))
+	}
+}
@@ -0,0 +1,208 @@
+// Package godav - Remote file comparison
+//
+// This file decides whether SkipExisting may skip an upload, according to
+// Config.CompareMode, and computes the local checksums compared with the
+// server's oc:checksums property.
+//
+// Features:
+//   - Size, size+mtime, checksum and always-upload comparison modes
+//   - Remote checksums read with a PROPFIND for oc:checksums
+//   - SHA1, MD5 and ADLER32 local hashing through a HashCache
+package godav
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumAlgorithms lists the oc:checksums algorithms godav can verify, in
+// order of preference.
+var checksumAlgorithms = []string{"SHA1", "MD5", "ADLER32"}
+
+// checksumPropfind requests only the oc:checksums property.
+const checksumPropfind = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:prop><oc:checksums/></d:prop></d:propfind>`
+
+// checksumMultistatus is the subset of a PROPFIND response holding oc:checksums.
+type checksumMultistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Checksums []string `xml:"prop>checksums>checksum"`
+			Status    string   `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// remoteUnchanged reports whether the remote file at finalPath matches source
+// under Config.CompareMode. On a match it also returns the message for the
+// skip event.
+func (c *Client) remoteUnchanged(ctx context.Context, finalPath string, source uploadSource) (bool, string) {
+	mode := c.config.CompareMode
+	if mode == CompareAlways {
+		return false, ""
+	}
+
+	info, err := c.Stat(finalPath)
+	if err != nil || info.IsDir() {
+		return false, ""
+	}
+	size, modTime, err := source.stat()
+	if err != nil || info.Size() != size {
+		return false, ""
+	}
+
+	// A stream cannot be hashed before it is uploaded
+	if mode == CompareChecksum && source.r != nil {
+		mode = CompareSizeMtime
+	}
+
+	switch mode {
+	case CompareSizeMtime:
+		if modTime.IsZero() || info.ModTime().Unix() != modTime.Unix() {
+			return false, ""
+		}
+		return true, "File already exists with same size and modification time"
+	case CompareChecksum:
+		remote, err := c.remoteChecksums(ctx, finalPath)
+		if err != nil {
+			if c.config.Verbose {
+				log.Printf("checksums %s: %v", finalPath, err)
+			}
+			return false, ""
+		}
+		for _, alg := range checksumAlgorithms {
+			want, ok := remote[alg]
+			if !ok {
+				continue
+			}
+			got, err := c.localChecksum(source.localPath, alg)
+			if err != nil || !strings.EqualFold(got, want) {
+				return false, ""
+			}
+			return true, fmt.Sprintf("File already exists with same checksum (%s)", alg)
+		}
+		// The server has no usable checksum for this file
+		return false, ""
+	default:
+		return true, "File already exists with same size"
+	}
+}
+
+// remoteChecksums returns the checksums the server stores for finalPath,
+// keyed by upper-case algorithm name.
+func (c *Client) remoteChecksums(ctx context.Context, finalPath string) (map[string]string, error) {
+	hdr := make(http.Header)
+	hdr.Set("Depth", "0")
+	hdr.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := c.davRequest(ctx, "PROPFIND", finalPath, strings.NewReader(checksumPropfind), hdr)
+	if err != nil {
+		return nil, fmt.Errorf("propfind %s: %w", finalPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("propfind %s: %s", finalPath, resp.Status)
+	}
+
+	var ms checksumMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("propfind %s: %w", finalPath, err)
+	}
+	sums := make(map[string]string)
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			for _, field := range ps.Checksums {
+				// Nextcloud lists several checksums in one element: "SHA1:... MD5:..."
+				for _, sum := range strings.Fields(field) {
+					if alg, value, ok := strings.Cut(sum, ":"); ok {
+						sums[strings.ToUpper(alg)] = value
+					}
+				}
+			}
+		}
+	}
+	return sums, nil
+}
+
+// localChecksum returns the hex-encoded alg hash of a local file, consulting
+// the hash cache first.
+func (c *Client) localChecksum(localPath, alg string) (string, error) {
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return "", fmt.Errorf("open %s: %w", localPath, err)
+	}
+	defer f.Close()
+	before, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("stat %s: %w", localPath, err)
+	}
+
+	cache := c.config.HashCache
+	if cache == nil {
+		cache = c.hashes
+	}
+	key := hashKeyFor(abs, before, alg)
+	if sum, ok := cache.Get(key); ok {
+		return sum, nil
+	}
+
+	h, err := newChecksumHash(alg)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", localPath, err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	// Only cache the hash if the file did not change while it was read
+	if after, err := f.Stat(); err == nil && after.Size() == before.Size() && after.ModTime().Equal(before.ModTime()) {
+		cache.Put(key, sum)
+	}
+	return sum, nil
+}
+
+// hashKeyFor builds the cache key for a file version.
+func hashKeyFor(absPath string, info os.FileInfo, alg string) HashKey {
+	dev, inode := fileIdentity(info)
+	return HashKey{
+		Path:      absPath,
+		Dev:       dev,
+		Inode:     inode,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Algorithm: alg,
+	}
+}
+
+// newChecksumHash returns a hash for an oc:checksums algorithm name.
+func newChecksumHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case "SHA1":
+		return sha1.New(), nil
+	case "MD5":
+		return md5.New(), nil
+	case "ADLER32":
+		return adler32.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", alg)
+	}
+}
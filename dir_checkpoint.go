@@ -0,0 +1,244 @@
+// Package godav - Directory upload checkpoints
+//
+// This file provides resumable directory uploads. While UploadDir runs with
+// Config.DirCheckpointPath set, it appends to a journal file: a header naming
+// the local and remote directories, one record per completed entry and the
+// latest Checkpoint of the file currently uploading. ResumeDir replays the
+// journal, skips completed entries without contacting the server and resumes
+// the interrupted file from its checkpoint.
+//
+// Features:
+//   - Append-only JSON-lines journal (cheap for very large trees)
+//   - In-flight file checkpoints for mid-file resumption
+//   - Journal removal once the directory upload completes without failures
+package godav
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Journal record types
+const (
+	dirRecordStart      = "start"
+	dirRecordDone       = "done"
+	dirRecordCheckpoint = "checkpoint"
+)
+
+// DirCheckpoint is the state of an interrupted directory upload, as recovered
+// from its journal by LoadDirCheckpoint.
+type DirCheckpoint struct {
+	LocalDir     string          // Local directory being uploaded
+	RemoteDir    string          // Remote destination directory
+	Completed    map[string]bool // Relative paths of entries already finished
+	InFlightPath string          // Relative path of the file that was uploading
+	InFlight     *Checkpoint     // Latest checkpoint of that file (nil if none)
+	Timestamp    time.Time       // Time of the last journal record
+}
+
+// dirJournalRecord is a single line of the directory journal.
+type dirJournalRecord struct {
+	Type       string      `json:"type"`
+	LocalDir   string      `json:"local_dir,omitempty"`
+	RemoteDir  string      `json:"remote_dir,omitempty"`
+	Path       string      `json:"path,omitempty"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	Time       time.Time   `json:"time"`
+}
+
+// dirJournal appends progress records for a directory upload.
+type dirJournal struct {
+	path  string
+	f     *os.File
+	state *DirCheckpoint
+	mu    sync.Mutex
+}
+
+// LoadDirCheckpoint reads a directory journal written by UploadDir.
+//
+// Example:
+//
+//	cp, err := godav.LoadDirCheckpoint("/tmp/photos.journal")
+//	if err == nil {
+//		fmt.Printf("%d entries already uploaded\n", len(cp.Completed))
+//	}
+func LoadDirCheckpoint(journalPath string) (*DirCheckpoint, error) {
+	f, err := os.Open(journalPath)
+	if err != nil {
+		return nil, fmt.Errorf("open dir journal: %w", err)
+	}
+	defer f.Close()
+
+	state := &DirCheckpoint{Completed: make(map[string]bool)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec dirJournalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn line left by a crash only costs a re-upload or an older
+			// checkpoint, so it is ignored rather than failing the resume
+			continue
+		}
+
+		state.Timestamp = rec.Time
+		switch rec.Type {
+		case dirRecordStart:
+			state.LocalDir = rec.LocalDir
+			state.RemoteDir = rec.RemoteDir
+		case dirRecordDone:
+			state.Completed[rec.Path] = true
+			if rec.Path == state.InFlightPath {
+				state.InFlightPath = ""
+				state.InFlight = nil
+			}
+		case dirRecordCheckpoint:
+			state.InFlightPath = rec.Path
+			state.InFlight = rec.Checkpoint
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read dir journal: %w", err)
+	}
+	if state.LocalDir == "" {
+		return nil, fmt.Errorf("dir journal %s has no start record", journalPath)
+	}
+	return state, nil
+}
+
+// ResumeDir resumes a directory upload from the journal written while
+// Config.DirCheckpointPath was set. Entries completed by the previous run are
+// not uploaded or checked against the server again, and the file that was
+// uploading when the run stopped continues from its last checkpoint.
+//
+// The journal keeps being appended to, so an interrupted ResumeDir can itself
+// be resumed. It is removed once the directory upload completes without failures.
+//
+// Example:
+//
+//	cfg := godav.DefaultConfig()
+//	cfg.DirCheckpointPath = "/tmp/photos.journal"
+//	client.SetConfig(cfg)
+//	if _, err := client.UploadDir("/data/photos", "Photos"); err != nil {
+//		// ... later, possibly after a restart:
+//		result, err := client.ResumeDir("/tmp/photos.journal")
+//	}
+func (c *Client) ResumeDir(journalPath string) (*DirUploadResult, error) {
+	c.config = c.validateConfig()
+	started := time.Now()
+
+	state, err := LoadDirCheckpoint(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open dir journal: %w", err)
+	}
+	// Terminate a torn last line so new records start on a line of their own
+	if _, err := f.Write([]byte("\n")); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write dir journal: %w", err)
+	}
+	journal := &dirJournal{path: journalPath, f: f, state: state}
+
+	c.emitEvent(EventDirScanStarted, filepath.Base(state.LocalDir), state.RemoteDir, "Scanning directory", nil)
+	entries, err := c.scanDir(state.LocalDir)
+	if err != nil {
+		_ = journal.close(false)
+		return nil, err
+	}
+
+	result, err := c.uploadDirEntries(context.Background(), state.LocalDir, state.RemoteDir, entries, started, journal)
+	_ = journal.close(err == nil)
+	return result, err
+}
+
+// createDirJournal starts a new journal for a directory upload, replacing any
+// previous journal at the same path.
+func createDirJournal(journalPath, localDir, remoteDir string) (*dirJournal, error) {
+	f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create dir journal: %w", err)
+	}
+	j := &dirJournal{
+		path: journalPath,
+		f:    f,
+		state: &DirCheckpoint{
+			LocalDir:  localDir,
+			RemoteDir: remoteDir,
+			Completed: make(map[string]bool),
+		},
+	}
+	if err := j.append(dirJournalRecord{Type: dirRecordStart, LocalDir: localDir, RemoteDir: remoteDir}); err != nil {
+		_ = j.close(false)
+		return nil, err
+	}
+	return j, nil
+}
+
+// isDone reports whether a previous run already finished relPath.
+func (j *dirJournal) isDone(relPath string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state.Completed[relPath]
+}
+
+// resumePoint returns the checkpoint to resume relPath from, if any. A
+// checkpoint of another version of the file, or of another chunk size, is
+// dropped.
+func (j *dirJournal) resumePoint(relPath string, info os.FileInfo, chunkSize int64) *Checkpoint {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state.InFlightPath != relPath || j.state.InFlight == nil {
+		return nil
+	}
+	if !j.state.InFlight.matchesFile(info, chunkSize) {
+		j.state.InFlightPath, j.state.InFlight = "", nil
+		return nil
+	}
+	return j.state.InFlight
+}
+
+// markDone records relPath as finished.
+func (j *dirJournal) markDone(relPath string) error {
+	return j.append(dirJournalRecord{Type: dirRecordDone, Path: relPath})
+}
+
+// saveCheckpoint records the latest checkpoint of the in-flight file.
+func (j *dirJournal) saveCheckpoint(relPath string, cp Checkpoint) error {
+	return j.append(dirJournalRecord{Type: dirRecordCheckpoint, Path: relPath, Checkpoint: &cp})
+}
+
+func (j *dirJournal) append(rec dirJournalRecord) error {
+	rec.Time = time.Now()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal dir journal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write dir journal: %w", err)
+	}
+	return nil
+}
+
+// close closes the journal file and removes it when remove is true.
+func (j *dirJournal) close(remove bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.f.Close()
+	if remove {
+		if rerr := os.Remove(j.path); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
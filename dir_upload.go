@@ -0,0 +1,596 @@
+// Package godav - Recursive directory uploads
+//
+// This file implements UploadDir. A directory upload first pre-scans the local
+// tree to total its files and bytes, then recreates the directory structure
+// remotely and uploads each file through the chunked uploader.
+//
+// Features:
+//   - Pre-scan with file and byte totals
+//   - Aggregate progress (files, bytes, current files, throughput, ETA)
+//   - Directory-level lifecycle events
+//   - Structured results with failure files for targeted retries
+//   - Symlink (follow/skip/describe), hidden and special file policies
+package godav
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// symlinkSuffix is appended to the remote name of link-description files.
+const symlinkSuffix = ".symlink"
+
+// dirEntry describes a file or directory found by the pre-scan.
+type dirEntry struct {
+	localPath  string    // Local path of the entry
+	relPath    string    // Slash-separated path relative to the upload root
+	isDir      bool      // Whether the entry is a directory
+	size       int64     // File size in bytes (0 for directories)
+	modTime    time.Time // Local modification time
+	linkTarget string    // Symlink target when uploaded as a link-description file
+	skipReason string    // Why the scan excluded this entry (empty if included)
+}
+
+// dirFailure is the on-disk form of a failed entry written by WriteFailures.
+type dirFailure struct {
+	RelPath    string `json:"rel_path"`
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+	IsDir      bool   `json:"is_dir,omitempty"`
+	LinkTarget string `json:"link_target,omitempty"`
+	Size       int64  `json:"size"`
+	Error      string `json:"error"`
+}
+
+// dirFailureList is the failures file written by WriteFailures.
+type dirFailureList struct {
+	LocalDir  string       `json:"local_dir"`
+	RemoteDir string       `json:"remote_dir"`
+	Failures  []dirFailure `json:"failures"`
+}
+
+// dirProgress aggregates per-file progress into DirProgressInfo updates.
+type dirProgress struct {
+	localDir     string
+	fn           func(info DirProgressInfo)
+	started      time.Time
+	filesTotal   int
+	filesDone    int
+	filesSkipped int
+	filesFailed  int
+	bytesTotal   int64
+	bytesDone    int64            // Bytes of finished files
+	transferred  int64            // Bytes actually sent over the wire
+	current      map[string]int64 // relPath -> bytes sent for in-flight files
+	mu           sync.Mutex
+}
+
+// UploadDir uploads a directory recursively using chunked uploads.
+//
+// The directory is pre-scanned first so that DirProgressFunc can report totals,
+// throughput and an ETA. EventDirScanStarted, EventDirScanDone and
+// EventDirComplete are emitted through EventFunc around the per-file events.
+//
+// Every entry is accounted for in the returned DirUploadResult. A non-nil error
+// is returned when the scan fails or when any entry failed; in the latter case
+// the result is still returned and lists the failures. Set DirFailFast to stop
+// at the first failure.
+//
+// Example:
+//
+//	cfg := godav.DefaultConfig()
+//	cfg.DirProgressFunc = func(p godav.DirProgressInfo) {
+//		fmt.Printf("%d/%d files, %d/%d bytes, ETA %s\n",
+//			p.FilesDone, p.FilesTotal, p.BytesDone, p.BytesTotal, p.ETA.Round(time.Second))
+//	}
+//	client.SetConfig(cfg)
+//	result, err := client.UploadDir("/path/to/local/dir", "remote/dir")
+//	if err != nil && result != nil {
+//		_ = result.WriteFailures("/tmp/failed.json") // retry later with RetryDirFailures
+//	}
+func (c *Client) UploadDir(localDir, dstDir string) (*DirUploadResult, error) {
+	c.config = c.validateConfig()
+	started := time.Now()
+
+	c.emitEvent(EventDirScanStarted, filepath.Base(localDir), dstDir, "Scanning directory", nil)
+	entries, err := c.scanDir(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.DirCheckpointPath == "" {
+		return c.uploadDirEntries(context.Background(), localDir, dstDir, entries, started, nil)
+	}
+
+	journal, err := createDirJournal(c.config.DirCheckpointPath, localDir, dstDir)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.uploadDirEntries(context.Background(), localDir, dstDir, entries, started, journal)
+	_ = journal.close(err == nil)
+	return result, err
+}
+
+// RetryDirFailures re-uploads the entries recorded by DirUploadResult.WriteFailures.
+// The returned result only covers the retried entries.
+func (c *Client) RetryDirFailures(failuresPath string) (*DirUploadResult, error) {
+	c.config = c.validateConfig()
+	started := time.Now()
+
+	data, err := os.ReadFile(failuresPath)
+	if err != nil {
+		return nil, fmt.Errorf("read failures file: %w", err)
+	}
+	var list dirFailureList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal failures file: %w", err)
+	}
+
+	entries := make([]dirEntry, 0, len(list.Failures))
+	for _, f := range list.Failures {
+		entry := dirEntry{
+			localPath:  f.LocalPath,
+			relPath:    f.RelPath,
+			isDir:      f.IsDir,
+			size:       f.Size,
+			linkTarget: f.LinkTarget,
+		}
+		// Refresh size and mtime in case the file changed since the failure
+		if info, err := os.Stat(f.LocalPath); err == nil && !info.IsDir() && f.LinkTarget == "" {
+			entry.size = info.Size()
+			entry.modTime = info.ModTime()
+		}
+		entries = append(entries, entry)
+	}
+
+	return c.uploadDirEntries(context.Background(), list.LocalDir, list.RemoteDir, entries, started, nil)
+}
+
+// uploadDirEntries creates or uploads the pre-scanned entries below dstDir and
+// records the outcome of each one. When journal is non-nil, entries it already
+// lists as done are passed over and newly finished entries are appended to it.
+func (c *Client) uploadDirEntries(ctx context.Context, localDir, dstDir string, entries []dirEntry, started time.Time, journal *dirJournal) (*DirUploadResult, error) {
+	dirName := filepath.Base(localDir)
+	result := &DirUploadResult{
+		LocalDir:  localDir,
+		RemoteDir: dstDir,
+		StartedAt: started,
+	}
+
+	progress := newDirProgress(localDir, entries, c.config.DirProgressFunc)
+	c.emitEvent(EventDirScanDone, dirName, dstDir,
+		fmt.Sprintf("Found %d files (%d bytes)", progress.filesTotal, progress.bytesTotal), nil)
+
+	for _, entry := range entries {
+		if journal != nil && journal.isDone(entry.relPath) {
+			result.AlreadyDone++
+			progress.preload(entry)
+			continue
+		}
+
+		remotePath := c.pathJoin(dstDir, entry.relPath)
+		res := DirEntryResult{
+			RelPath:    entry.relPath,
+			LocalPath:  entry.localPath,
+			RemotePath: remotePath,
+			IsDir:      entry.isDir,
+			Size:       entry.size,
+		}
+		entryStart := time.Now()
+
+		skipped := false
+		switch {
+		case entry.skipReason != "":
+			skipped = true
+			res.Reason = entry.skipReason
+			if c.config.Verbose {
+				log.Printf("Skip %s: %s", entry.localPath, entry.skipReason)
+			}
+			c.emitEvent(EventUploadSkipped, filepath.Base(entry.localPath), remotePath, "Skipped: "+entry.skipReason, nil)
+		case entry.isDir:
+			// Create directory
+			finalPath := c.toFilesPath(remotePath)
+			if err := c.MkdirAll(finalPath, 0o755); err != nil && !c.isAlreadyExists(err) {
+				res.Err = fmt.Errorf("mkdir %s: %w", finalPath, err)
+			}
+		case entry.linkTarget != "":
+			res.LinkTarget = entry.linkTarget
+			res.RemotePath = remotePath + symlinkSuffix
+			res.Err = c.uploadLinkDescription(entry, res.RemotePath, progress)
+		default:
+			skipped, res.Err = c.uploadDirFile(ctx, entry, remotePath, progress, journal)
+			if skipped {
+				res.Reason = "already exists"
+			}
+		}
+		res.Duration = time.Since(entryStart)
+
+		result.add(res, skipped)
+		if res.Err != nil && c.config.Verbose {
+			log.Printf("upload %s: %v", remotePath, res.Err)
+		}
+
+		if res.Err == nil && journal != nil {
+			if err := journal.markDone(entry.relPath); err != nil && c.config.Verbose {
+				log.Printf("dir journal %s: %v", journal.path, err)
+			}
+		}
+		if res.Err != nil && c.config.DirFailFast {
+			break
+		}
+	}
+
+	result.Duration = time.Since(started)
+	c.emitEvent(EventDirComplete, dirName, dstDir,
+		fmt.Sprintf("%d uploaded, %d skipped, %d failed", len(result.Uploaded), len(result.Skipped), len(result.Failed)),
+		result.Err())
+	return result, result.Err()
+}
+
+// add records the outcome of a single entry.
+func (r *DirUploadResult) add(res DirEntryResult, skipped bool) {
+	switch {
+	case res.Err != nil:
+		r.Failed = append(r.Failed, res)
+		r.BytesFailed += res.Size
+	case skipped:
+		r.Skipped = append(r.Skipped, res)
+		r.BytesSkipped += res.Size
+	case res.IsDir:
+		// Directories are not reported unless they fail or are skipped
+	default:
+		r.Uploaded = append(r.Uploaded, res)
+		r.BytesUploaded += res.Size
+	}
+}
+
+// Err returns an error summarizing the failed entries, or nil if none failed.
+// The first failure is wrapped so it can be inspected with errors.Is/As.
+func (r *DirUploadResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	first := r.Failed[0]
+	op, p := "upload dir", r.LocalDir
+	if r.archive {
+		// Archive uploads have no local directory
+		op, p = "upload archive", r.RemoteDir
+	}
+	return &UploadError{
+		Op:   op,
+		Path: p,
+		Err:  fmt.Errorf("%d entries failed, first %s: %w", len(r.Failed), first.RelPath, first.Err),
+	}
+}
+
+// WriteFailures writes the failed entries to a JSON file that can be passed to
+// RetryDirFailures to retry just those entries.
+func (r *DirUploadResult) WriteFailures(path string) error {
+	list := dirFailureList{
+		LocalDir:  r.LocalDir,
+		RemoteDir: r.RemoteDir,
+		Failures:  make([]dirFailure, 0, len(r.Failed)),
+	}
+	for _, f := range r.Failed {
+		failure := dirFailure{
+			RelPath:    f.RelPath,
+			LocalPath:  f.LocalPath,
+			RemotePath: f.RemotePath,
+			IsDir:      f.IsDir,
+			LinkTarget: f.LinkTarget,
+			Size:       f.Size,
+		}
+		if f.Err != nil {
+			failure.Error = f.Err.Error()
+		}
+		list.Failures = append(list.Failures, failure)
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal failures: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// scanDir walks localDir and returns every entry below it in walk order.
+// Entries excluded by the symlink, hidden and special file policies are
+// returned with a skipReason so they can be reported.
+func (c *Client) scanDir(localDir string) ([]dirEntry, error) {
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", localDir)
+	}
+	realRoot, err := filepath.EvalSymlinks(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []dirEntry
+	ancestors := map[string]bool{realRoot: true}
+	err = c.walkDir(localDir, "", ancestors, &entries)
+	return entries, err
+}
+
+// walkDir appends the entries of dir to entries, descending into directories.
+// ancestors holds the resolved paths of the directories on the current branch
+// and is used to detect symlink cycles.
+func (c *Client) walkDir(dir, rel string, ancestors map[string]bool, entries *[]dirEntry) error {
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		localPath := filepath.Join(dir, item.Name())
+		info, err := os.Lstat(localPath)
+		if err != nil {
+			return err
+		}
+		entry := dirEntry{
+			localPath: localPath,
+			relPath:   path.Join(rel, item.Name()),
+			isDir:     info.IsDir(),
+			modTime:   info.ModTime(),
+		}
+
+		if c.config.SkipHidden && strings.HasPrefix(item.Name(), ".") {
+			entry.skipReason = "hidden"
+			*entries = append(*entries, entry)
+			continue
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			switch c.config.SymlinkPolicy {
+			case SymlinkSkip:
+				entry.skipReason = "symlink"
+				*entries = append(*entries, entry)
+				continue
+			case SymlinkAsFile:
+				target, err := os.Readlink(localPath)
+				if err != nil {
+					return err
+				}
+				entry.linkTarget = target
+				entry.size = int64(len(linkDescription(target)))
+				*entries = append(*entries, entry)
+				continue
+			}
+
+			// SymlinkFollow: continue with the link target
+			if info, err = os.Stat(localPath); err != nil {
+				entry.skipReason = "broken symlink"
+				*entries = append(*entries, entry)
+				continue
+			}
+			entry.isDir = info.IsDir()
+			entry.modTime = info.ModTime()
+		}
+
+		switch {
+		case info.IsDir():
+			target, err := filepath.EvalSymlinks(localPath)
+			if err != nil {
+				return err
+			}
+			if ancestors[target] {
+				entry.skipReason = "symlink cycle"
+				*entries = append(*entries, entry)
+				continue
+			}
+			*entries = append(*entries, entry)
+
+			ancestors[target] = true
+			err = c.walkDir(localPath, entry.relPath, ancestors, entries)
+			delete(ancestors, target)
+			if err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			entry.size = info.Size()
+			*entries = append(*entries, entry)
+		default:
+			entry.skipReason = specialFileReason(info.Mode())
+			*entries = append(*entries, entry)
+		}
+	}
+	return nil
+}
+
+// specialFileReason describes why a non-regular file cannot be uploaded.
+func specialFileReason(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device file"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	default:
+		return "special file"
+	}
+}
+
+// linkDescription returns the content of the file uploaded for a symlink
+// under SymlinkAsFile.
+func linkDescription(target string) string {
+	return "symlink -> " + target + "\n"
+}
+
+// uploadLinkDescription uploads the link-description file for a symlink entry.
+// The file is small, so it is written with a single PUT.
+func (c *Client) uploadLinkDescription(entry dirEntry, remotePath string, progress *dirProgress) error {
+	progress.update(entry.relPath, 0)
+	finalPath := c.toFilesPath(remotePath)
+	err := c.Write(finalPath, []byte(linkDescription(entry.linkTarget)), 0o644)
+	if err == nil {
+		progress.update(entry.relPath, entry.size)
+	}
+	progress.finish(entry.relPath, entry.size, false, err)
+	return err
+}
+
+// uploadDirFile uploads a single directory entry, feeding its chunk progress
+// into the aggregate directory progress. With a journal, the file resumes from
+// its journaled checkpoint and new checkpoints are journaled as they are taken.
+// It reports whether the file was skipped.
+func (c *Client) uploadDirFile(ctx context.Context, entry dirEntry, remotePath string, progress *dirProgress, journal *dirJournal) (bool, error) {
+	cfg := *c.config
+	cfg.ResumeFromCheckpoint = nil
+	userProgress := cfg.ProgressFunc
+	cfg.ProgressFunc = func(info ProgressInfo) {
+		if userProgress != nil {
+			userProgress(info)
+		}
+		progress.update(entry.relPath, info.Current)
+	}
+
+	if journal != nil {
+		if info, err := os.Stat(entry.localPath); err == nil {
+			cfg.ResumeFromCheckpoint = journal.resumePoint(entry.relPath, info, cfg.ChunkSize)
+		}
+		userCheckpoint := cfg.CheckpointFunc
+		cfg.CheckpointFunc = func(cp Checkpoint) {
+			if err := journal.saveCheckpoint(entry.relPath, cp); err != nil && cfg.Verbose {
+				log.Printf("dir journal %s: %v", journal.path, err)
+			}
+			if userCheckpoint != nil {
+				userCheckpoint(cp)
+			}
+		}
+	}
+
+	progress.update(entry.relPath, 0)
+	skipped, err := c.withConfig(&cfg).uploadFileCore(ctx, entry.localPath, remotePath)
+	progress.finish(entry.relPath, entry.size, skipped, err)
+	return skipped, err
+}
+
+// newDirProgress creates a progress tracker from the pre-scanned entries.
+func newDirProgress(localDir string, entries []dirEntry, fn func(info DirProgressInfo)) *dirProgress {
+	p := &dirProgress{
+		localDir: localDir,
+		fn:       fn,
+		started:  time.Now(),
+		current:  make(map[string]int64),
+	}
+	for _, e := range entries {
+		if e.isDir || e.skipReason != "" {
+			continue
+		}
+		p.filesTotal++
+		p.bytesTotal += e.size
+	}
+	return p
+}
+
+// add counts a file found while uploading, for sources that are not
+// pre-scanned such as archives.
+func (p *dirProgress) add(size int64) {
+	p.mu.Lock()
+	p.filesTotal++
+	p.bytesTotal += size
+	p.mu.Unlock()
+}
+
+// preload counts an entry finished by a previous run as done without
+// reporting it or treating its bytes as transferred.
+func (p *dirProgress) preload(entry dirEntry) {
+	if entry.isDir || entry.skipReason != "" {
+		return
+	}
+	p.mu.Lock()
+	p.filesDone++
+	p.bytesDone += entry.size
+	p.mu.Unlock()
+}
+
+// update records the bytes sent so far for an in-flight file.
+func (p *dirProgress) update(relPath string, sent int64) {
+	p.mu.Lock()
+	if prev, ok := p.current[relPath]; ok && sent > prev {
+		p.transferred += sent - prev
+	}
+	p.current[relPath] = sent
+	info := p.snapshotLocked()
+	p.mu.Unlock()
+
+	if p.fn != nil {
+		p.fn(info)
+	}
+}
+
+// finish marks a file as done and reports the new totals.
+func (p *dirProgress) finish(relPath string, size int64, skipped bool, err error) {
+	p.mu.Lock()
+	delete(p.current, relPath)
+	p.filesDone++
+	p.bytesDone += size
+	switch {
+	case err != nil:
+		p.filesFailed++
+	case skipped:
+		p.filesSkipped++
+	}
+	info := p.snapshotLocked()
+	p.mu.Unlock()
+
+	if p.fn != nil {
+		p.fn(info)
+	}
+}
+
+// snapshot returns the current aggregate progress.
+func (p *dirProgress) snapshot() DirProgressInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshotLocked()
+}
+
+func (p *dirProgress) snapshotLocked() DirProgressInfo {
+	done := p.bytesDone
+	current := make([]string, 0, len(p.current))
+	for rel, sent := range p.current {
+		done += sent
+		current = append(current, rel)
+	}
+	sort.Strings(current)
+
+	info := DirProgressInfo{
+		LocalDir:     p.localDir,
+		FilesTotal:   p.filesTotal,
+		FilesDone:    p.filesDone,
+		FilesSkipped: p.filesSkipped,
+		FilesFailed:  p.filesFailed,
+		BytesTotal:   p.bytesTotal,
+		BytesDone:    done,
+		CurrentFiles: current,
+		Elapsed:      time.Since(p.started),
+	}
+	if p.bytesTotal > 0 {
+		info.Percentage = float64(done) / float64(p.bytesTotal) * 100.0
+	} else if p.filesTotal > 0 {
+		info.Percentage = float64(p.filesDone) / float64(p.filesTotal) * 100.0
+	}
+	if secs := info.Elapsed.Seconds(); secs > 0 && p.transferred > 0 {
+		info.BytesPerSecond = float64(p.transferred) / secs
+		if remain := p.bytesTotal - done; remain > 0 {
+			info.ETA = time.Duration(float64(remain) / info.BytesPerSecond * float64(time.Second))
+		}
+	}
+	return info
+}
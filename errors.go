@@ -1,6 +1,17 @@
 package godav
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUploadCancelled is returned by uploads stopped through
+// UploadController.Cancel or UploadManager.CancelUpload.
+var ErrUploadCancelled = errors.New("upload cancelled")
+
+// ErrPauseTimeout is returned by uploads paused for longer than
+// Config.PauseTimeout.
+var ErrPauseTimeout = errors.New("upload paused timeout")
 
 // UploadError represents errors that occur during upload
 type UploadError struct {
@@ -25,7 +25,11 @@ type UploadService struct {
 
 func NewUploadService() *UploadService {
 	s := &UploadService{
-		manager: godav.NewUploadManager(),
+		manager: godav.NewUploadManagerWithConfig(godav.ManagerConfig{
+			MaxConcurrent: 8,
+			Fairness:      godav.FairnessRoundRobin,            // users take turns for free slots
+			OwnerLimits:   godav.OwnerLimits{MaxConcurrent: 2}, // per user
+		}),
 		clients: make(map[string]*godav.Client),
 		subs:    make(map[string]map[chan string]struct{}),
 	}
@@ -111,7 +115,7 @@ func (s *UploadService) getOrCreateClient(userID, baseURL, username, password st
 // StartUpload starts a session for a user and returns the session ID.
 func (s *UploadService) StartUpload(userID, baseURL, davUser, davPass, localPath, remotePath string) (string, error) {
 	client := s.getOrCreateClient(userID, baseURL, davUser, davPass)
-	sess, err := s.manager.AddUploadSession(localPath, remotePath, client)
+	sess, err := s.manager.AddUploadSessionWithOptions(localPath, remotePath, client, godav.SessionOptions{Owner: userID})
 	if err != nil {
 		log.Printf("upload: add-session error user=%s local=%s remote=%s err=%v", userID, localPath, remotePath, err)
 		return "", err
@@ -133,13 +137,11 @@ func (s *UploadService) Resume(id string) error {
 	return s.manager.ResumeUpload(id)
 }
 func (s *UploadService) Cancel(id string) error {
-	sess, err := s.manager.GetUploadSession(id)
-	if err != nil {
-		log.Printf("upload: cancel get-session error session=%s err=%v", id, err)
+	if err := s.manager.CancelUpload(id); err != nil {
+		log.Printf("upload: cancel error session=%s err=%v", id, err)
 		return err
 	}
-	sess.Controller.Cancel()
-	log.Printf("upload: cancel signalled session=%s", id)
+	log.Printf("upload: cancelled session=%s", id)
 	return nil
 }
 func (s *UploadService) Status(id string) (godav.UploadStatus, error) {
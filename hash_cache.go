@@ -0,0 +1,386 @@
+// Package godav - Local file hash cache
+//
+// This file provides the cache consulted before hashing a local file, both when
+// CompareChecksum decides whether to skip a file and when an upload sends its
+// OC-Checksum. Entries are keyed by device, inode, size and modification time,
+// so a file is only rehashed after it changes, even if it was renamed.
+//
+// Features:
+//   - HashCache interface for custom stores
+//   - Concurrency-safe in-memory implementation, capped with LRU eviction
+//   - Persistent single-file implementation (FileHashCache) with invalidation,
+//     pruning, compaction and an entry cap
+package godav
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HashCache stores content hashes of local files.
+// Implementations must be safe for concurrent use.
+type HashCache interface {
+	// Get returns the hash stored for key, if any.
+	Get(key HashKey) (string, bool)
+	// Put stores the hash of the file identified by key.
+	Put(key HashKey, sum string)
+}
+
+// HashKey identifies a version of a local file. A cached hash is only valid
+// while the file's size and modification time are unchanged.
+type HashKey struct {
+	Path      string    // Absolute local path
+	Dev       uint64    // Device number (0 where unavailable)
+	Inode     uint64    // Inode number (0 where unavailable)
+	Size      int64     // File size in bytes
+	ModTime   time.Time // File modification time
+	Algorithm string    // Hash algorithm, e.g. "SHA1"
+}
+
+// DefaultHashCacheMaxEntries is the entry cap of the in-memory cache, and the
+// one used by OpenFileHashCache when maxEntries is not positive.
+const DefaultHashCacheMaxEntries = 100000
+
+// memoryHashCache is the default HashCache: a map that lives as long as
+// the client, holding at most maxEntries hashes.
+type memoryHashCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[HashKey]*memoryHashEntry
+	tick       uint64 // Use counter, for eviction
+}
+
+// memoryHashEntry is a hash of the in-memory cache.
+type memoryHashEntry struct {
+	sum  string
+	used uint64 // Value of tick at the last use
+}
+
+// NewMemoryHashCache creates an in-memory HashCache that keeps the
+// DefaultHashCacheMaxEntries most recently used hashes.
+func NewMemoryHashCache() HashCache {
+	return &memoryHashCache{
+		maxEntries: DefaultHashCacheMaxEntries,
+		entries:    make(map[HashKey]*memoryHashEntry),
+	}
+}
+
+func (m *memoryHashCache) Get(key HashKey) (string, bool) {
+	key.ModTime = key.ModTime.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok {
+		return "", false
+	}
+	m.tick++
+	e.used = m.tick
+	return e.sum, true
+}
+
+func (m *memoryHashCache) Put(key HashKey, sum string) {
+	key.ModTime = key.ModTime.UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tick++
+	m.entries[key] = &memoryHashEntry{sum: sum, used: m.tick}
+	if len(m.entries) > m.maxEntries {
+		m.evictLocked()
+	}
+}
+
+// evictLocked drops the least recently used entries down to 90% of the cap.
+func (m *memoryHashCache) evictLocked() {
+	live := make([]HashKey, 0, len(m.entries))
+	for key := range m.entries {
+		live = append(live, key)
+	}
+	sort.Slice(live, func(i, j int) bool { return m.entries[live[i]].used < m.entries[live[j]].used })
+	for _, key := range live[:len(live)-m.maxEntries*9/10] {
+		delete(m.entries, key)
+	}
+}
+
+// FileHashCache is a HashCache persisted to a single append-only file.
+//
+// Entries are identified by device and inode (falling back to the path where
+// those are unavailable) and are only returned while the file's size and
+// modification time still match, so renamed files keep their hash and edited
+// files are rehashed. A stale entry is replaced as soon as the new hash is put.
+// The file is compacted when superseded records outnumber live entries, and the
+// least recently used entries are evicted beyond the entry cap.
+//
+// A FileHashCache is safe for concurrent use within a process, but the file
+// must not be shared by several processes at once.
+type FileHashCache struct {
+	path       string
+	maxEntries int
+	f          *os.File
+	entries    map[string]*hashCacheEntry // identity|algorithm -> entry
+	records    int                        // Records in the file, including superseded ones
+	err        error                      // First write error, reported by Close
+	mu         sync.Mutex
+}
+
+// hashCacheEntry is a single record of the hash cache file.
+type hashCacheEntry struct {
+	ID        string `json:"id"`            // Device/inode identity or path
+	Path      string `json:"path"`          // Last known path of the file
+	Size      int64  `json:"size"`          // File size when hashed
+	ModTime   int64  `json:"mtime"`         // Modification time (Unix nanoseconds) when hashed
+	Algorithm string `json:"alg"`           // Hash algorithm
+	Sum       string `json:"sum,omitempty"` // Hex-encoded hash
+	Used      int64  `json:"used"`          // Last use (Unix seconds), for eviction
+	Deleted   bool   `json:"del,omitempty"` // Tombstone written by Invalidate
+}
+
+// OpenFileHashCache opens or creates the hash cache stored at path. At most
+// maxEntries hashes are kept (DefaultHashCacheMaxEntries if maxEntries <= 0).
+// Call Close when done to flush and release the file.
+//
+// Example:
+//
+//	cache, err := godav.OpenFileHashCache("/var/cache/myapp/hashes.db", 0)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer cache.Close()
+//	cfg := godav.DefaultConfig()
+//	cfg.CompareMode = godav.CompareChecksum
+//	cfg.HashCache = cache
+func OpenFileHashCache(path string, maxEntries int) (*FileHashCache, error) {
+	if maxEntries <= 0 {
+		maxEntries = DefaultHashCacheMaxEntries
+	}
+	h := &FileHashCache{
+		path:       path,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*hashCacheEntry),
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	if err := h.reopen(); err != nil {
+		return nil, err
+	}
+	if len(h.entries) > h.maxEntries {
+		h.evictLocked()
+	}
+	return h, nil
+}
+
+// Get returns the hash stored for key if the file has not changed since.
+func (h *FileHashCache) Get(key HashKey) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.entries[hashCacheIndex(key)]
+	if !ok || e.Size != key.Size || e.ModTime != key.ModTime.UnixNano() {
+		return "", false
+	}
+	e.Used = time.Now().Unix()
+	return e.Sum, true
+}
+
+// Put stores the hash of the file identified by key, replacing any entry for
+// an older version of the same file.
+func (h *FileHashCache) Put(key HashKey, sum string) {
+	e := &hashCacheEntry{
+		ID:        hashCacheID(key),
+		Path:      key.Path,
+		Size:      key.Size,
+		ModTime:   key.ModTime.UnixNano(),
+		Algorithm: key.Algorithm,
+		Sum:       sum,
+		Used:      time.Now().Unix(),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries[hashCacheIndex(key)] = e
+	h.appendLocked(e)
+	if len(h.entries) > h.maxEntries {
+		h.evictLocked()
+	} else if h.records > 2*len(h.entries)+1024 {
+		_ = h.compactLocked()
+	}
+}
+
+// Invalidate removes all entries for the file at path.
+func (h *FileHashCache) Invalidate(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for idx, e := range h.entries {
+		if e.Path == path {
+			delete(h.entries, idx)
+			h.appendLocked(&hashCacheEntry{ID: e.ID, Algorithm: e.Algorithm, Deleted: true})
+		}
+	}
+}
+
+// Prune removes entries whose file no longer exists or has changed since it
+// was hashed, then compacts the file. It returns the number of entries removed.
+func (h *FileHashCache) Prune() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	removed := 0
+	for idx, e := range h.entries {
+		info, err := os.Stat(e.Path)
+		if err == nil {
+			key := hashKeyFor(e.Path, info, e.Algorithm)
+			if hashCacheID(key) == e.ID && info.Size() == e.Size && info.ModTime().UnixNano() == e.ModTime {
+				continue
+			}
+		}
+		delete(h.entries, idx)
+		removed++
+	}
+	return removed, h.compactLocked()
+}
+
+// Compact rewrites the cache file with only the live entries.
+func (h *FileHashCache) Compact() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.compactLocked()
+}
+
+// Len returns the number of cached hashes.
+func (h *FileHashCache) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.entries)
+}
+
+// Close compacts and closes the cache file. It reports the first error met
+// while writing to the file, if any.
+func (h *FileHashCache) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := h.compactLocked()
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	if h.err != nil {
+		return h.err
+	}
+	return err
+}
+
+// load replays the cache file into memory.
+func (h *FileHashCache) load() error {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open hash cache: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e hashCacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn line left by a crash only costs a rehash
+			continue
+		}
+		h.records++
+		idx := e.ID + "|" + e.Algorithm
+		if e.Deleted {
+			delete(h.entries, idx)
+			continue
+		}
+		h.entries[idx] = &e
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read hash cache: %w", err)
+	}
+	return nil
+}
+
+// reopen opens the cache file for appending, terminating any torn last line.
+func (h *FileHashCache) reopen() error {
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open hash cache: %w", err)
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		if _, err := f.Write([]byte("\n")); err != nil {
+			f.Close()
+			return fmt.Errorf("write hash cache: %w", err)
+		}
+	}
+	h.f = f
+	return nil
+}
+
+func (h *FileHashCache) appendLocked(e *hashCacheEntry) {
+	data, err := json.Marshal(e)
+	if err == nil {
+		_, err = h.f.Write(append(data, '\n'))
+	}
+	if err != nil && h.err == nil {
+		h.err = fmt.Errorf("write hash cache: %w", err)
+	}
+	h.records++
+}
+
+// evictLocked drops the least recently used entries down to 90% of the cap
+// and compacts the file.
+func (h *FileHashCache) evictLocked() {
+	live := make([]string, 0, len(h.entries))
+	for idx := range h.entries {
+		live = append(live, idx)
+	}
+	sort.Slice(live, func(i, j int) bool { return h.entries[live[i]].Used < h.entries[live[j]].Used })
+	for _, idx := range live[:len(live)-h.maxEntries*9/10] {
+		delete(h.entries, idx)
+	}
+	_ = h.compactLocked()
+}
+
+// compactLocked atomically replaces the cache file with the live entries.
+func (h *FileHashCache) compactLocked() error {
+	var buf []byte
+	for _, e := range h.entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal hash cache entry: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if err := writeFileAtomic(h.path, buf, 0o600); err != nil {
+		return fmt.Errorf("compact hash cache: %w", err)
+	}
+
+	h.f.Close()
+	h.records = len(h.entries)
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		if h.err == nil {
+			h.err = fmt.Errorf("open hash cache: %w", err)
+		}
+		return err
+	}
+	h.f = f
+	return nil
+}
+
+// hashCacheID identifies the file behind key: by device and inode where
+// available so that renames keep their hash, by path otherwise.
+func hashCacheID(key HashKey) string {
+	if key.Dev == 0 && key.Inode == 0 {
+		return "path:" + key.Path
+	}
+	return strconv.FormatUint(key.Dev, 10) + ":" + strconv.FormatUint(key.Inode, 10)
+}
+
+func hashCacheIndex(key HashKey) string {
+	return hashCacheID(key) + "|" + key.Algorithm
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package godav
+
+import "os"
+
+// fileIdentity returns the device and inode numbers of a file. They are not
+// available on this platform, so hash cache entries are keyed by path.
+func fileIdentity(info os.FileInfo) (dev, inode uint64) {
+	return 0, 0
+}
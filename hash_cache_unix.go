@@ -0,0 +1,16 @@
+//go:build unix
+
+package godav
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode numbers of a file.
+func fileIdentity(info os.FileInfo) (dev, inode uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
@@ -0,0 +1,322 @@
+// Package godav - Upload manager event subscriptions
+//
+// This file lets applications observe an UploadManager through channels
+// instead of wiring ProgressFunc/EventFunc into every client's config.
+// Subscribers receive typed events for session status changes, progress,
+// upload lifecycle events and failures, filtered by session, client or type.
+//
+// Features:
+//   - Per-session, per-client and per-type filtering
+//   - Subscriber buffers with a drop or block overflow policy
+//   - Publishing never blocks the manager; a slow subscriber only delays itself
+//   - Unsubscribe function closing the channel
+package godav
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// ManagerEventType identifies the kind of a ManagerEvent
+type ManagerEventType string
+
+const (
+	ManagerEventStatus   ManagerEventType = "status"   // Session status changed
+	ManagerEventProgress ManagerEventType = "progress" // Upload progress of a session
+	ManagerEventUpload   ManagerEventType = "upload"   // Upload lifecycle event (EventFunc) of a session
+	ManagerEventError    ManagerEventType = "error"    // Session upload failed
+	ManagerEventRetry    ManagerEventType = "retry"    // Failed attempt, session queued for a retry
+	ManagerEventRemoved  ManagerEventType = "removed"  // Session removed, explicitly or by the retention policy
+
+	ManagerEventGroupComplete ManagerEventType = "group_complete" // Every session of a group finished
+)
+
+// ManagerEvent is an event published by an UploadManager to its subscribers.
+type ManagerEvent struct {
+	Type       ManagerEventType // Kind of event
+	SessionID  string           // Session the event belongs to (empty for group events)
+	GroupID    string           // Group of the session, or the finished group
+	Client     *Client          // Client of the session
+	Status     UploadStatus     // Session status (status, error and removed events)
+	PrevStatus UploadStatus     // Status before the change (ManagerEventStatus only)
+	Progress   *ProgressInfo    // Progress details (ManagerEventProgress only)
+	Upload     *EventInfo       // Upload event details (ManagerEventUpload only)
+	Err        error            // Failure cause (error and retry events)
+	Attempt    int              // Number of the failed attempt (ManagerEventRetry only)
+	RetryAt    time.Time        // When the next attempt may start (ManagerEventRetry only)
+	Time       time.Time        // When the event occurred
+}
+
+// OverflowPolicy decides what happens when a subscriber's buffer is full
+type OverflowPolicy int
+
+const (
+	OverflowDrop  OverflowPolicy = iota // Discard the event for that subscriber (default)
+	OverflowBlock                       // Queue the event until the subscriber reads or unsubscribes
+)
+
+// DefaultEventBufferSize is the subscriber buffer used when
+// EventFilter.BufferSize is not positive.
+const DefaultEventBufferSize = 64
+
+// EventFilter selects the events delivered to a subscriber. Empty fields
+// match everything.
+type EventFilter struct {
+	// SessionIDs restricts events to these sessions.
+	SessionIDs []string
+
+	// Client restricts events to sessions of this client.
+	Client *Client
+
+	// GroupIDs restricts events to sessions of these groups and to their
+	// completion events.
+	GroupIDs []string
+
+	// Types restricts events to these types.
+	Types []ManagerEventType
+
+	// BufferSize is the capacity of the subscriber channel.
+	// Default: DefaultEventBufferSize.
+	BufferSize int
+
+	// Overflow controls what happens when the channel is full.
+	// With OverflowBlock, no event is lost: events that do not fit wait in a
+	// queue of this subscriber, which grows until it reads or unsubscribes.
+	// Neither the manager nor other subscribers wait for it.
+	// Default: OverflowDrop.
+	Overflow OverflowPolicy
+}
+
+// match reports whether ev passes the filter.
+func (f *EventFilter) match(ev *ManagerEvent) bool {
+	if f.Client != nil && ev.Client != f.Client {
+		return false
+	}
+	if len(f.GroupIDs) > 0 && !slices.Contains(f.GroupIDs, ev.GroupID) {
+		return false
+	}
+	if len(f.SessionIDs) > 0 && !slices.Contains(f.SessionIDs, ev.SessionID) {
+		return false
+	}
+	if len(f.Types) > 0 && !slices.Contains(f.Types, ev.Type) {
+		return false
+	}
+	return true
+}
+
+// eventSubscriber is a single Subscribe registration.
+type eventSubscriber struct {
+	filter EventFilter
+	ch     chan ManagerEvent
+	done   chan struct{}  // Closed on unsubscribe
+	queue  []ManagerEvent // Events waiting for a full blocking subscriber
+	wake   chan struct{}  // Signals forward that queue is not empty
+	closed bool
+	once   sync.Once
+	mu     sync.Mutex // Guards queue and closed, serializes sends with closing ch
+}
+
+// deliver passes ev to the subscriber according to its overflow policy. It
+// never blocks: a dropping subscriber loses the event when its channel is
+// full, a blocking subscriber queues it for forward.
+func (s *eventSubscriber) deliver(ev ManagerEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.filter.Overflow == OverflowBlock {
+		s.queue = append(s.queue, ev)
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	select {
+	case s.ch <- ev:
+	default:
+	}
+}
+
+// forward moves the queued events of a blocking subscriber to its channel,
+// in order, waiting for the subscriber to read. It runs for the lifetime of
+// the subscription and closes the channel on unsubscribe.
+func (s *eventSubscriber) forward() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue[0] = ManagerEvent{}
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// eventBus fans manager events out to subscribers. Publishing never blocks,
+// so events can be published while um.mu is held: dropping subscribers are
+// bounded by their channel, and each blocking subscriber has its own queue
+// and delivery goroutine, so a slow subscriber only delays itself.
+type eventBus struct {
+	subs map[*eventSubscriber]struct{}
+	mu   sync.Mutex
+}
+
+// subscribe registers a subscriber and returns it.
+func (b *eventBus) subscribe(filter EventFilter) *eventSubscriber {
+	if filter.BufferSize <= 0 {
+		filter.BufferSize = DefaultEventBufferSize
+	}
+	sub := &eventSubscriber{
+		filter: filter,
+		ch:     make(chan ManagerEvent, filter.BufferSize),
+		done:   make(chan struct{}),
+	}
+	if filter.Overflow == OverflowBlock {
+		sub.wake = make(chan struct{}, 1)
+		go sub.forward()
+	}
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*eventSubscriber]struct{})
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// unsubscribe removes sub and closes its channel; a blocking subscriber's
+// channel is closed by its forward goroutine shortly after. It is safe to
+// call more than once.
+func (b *eventBus) unsubscribe(sub *eventSubscriber) {
+	sub.once.Do(func() {
+		close(sub.done)
+		b.mu.Lock()
+		delete(b.subs, sub)
+		b.mu.Unlock()
+
+		sub.mu.Lock()
+		sub.closed = true
+		sub.queue = nil
+		if sub.filter.Overflow != OverflowBlock {
+			close(sub.ch)
+		}
+		sub.mu.Unlock()
+	})
+}
+
+// publish delivers ev to the matching subscribers without blocking. Every
+// subscriber sees events in the order they were published.
+func (b *eventBus) publish(ev ManagerEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if sub.filter.match(&ev) {
+			sub.deliver(ev)
+		}
+	}
+}
+
+// Subscribe returns a channel of manager events matching filter and a
+// function that unsubscribes and closes the channel. Events are delivered in
+// the order they were published. Always call the unsubscribe function when
+// done, in particular with OverflowBlock, whose queue grows while unread.
+//
+// Example:
+//
+//	events, unsubscribe := manager.Subscribe(godav.EventFilter{
+//		SessionIDs: []string{session.ID},
+//		Types:      []godav.ManagerEventType{godav.ManagerEventStatus, godav.ManagerEventError},
+//	})
+//	defer unsubscribe()
+//	for ev := range events {
+//		fmt.Printf("%s: %s -> %s\n", ev.SessionID, ev.PrevStatus, ev.Status)
+//	}
+func (um *UploadManager) Subscribe(filter EventFilter) (<-chan ManagerEvent, func()) {
+	sub := um.events.subscribe(filter)
+	return sub.ch, func() { um.events.unsubscribe(sub) }
+}
+
+// setStatusLocked moves a session to status, saves it and publishes a status
+// event if the status changed. A finished session's done channel is closed.
+// um.mu must be held.
+func (um *UploadManager) setStatusLocked(sess *UploadSession, status UploadStatus) {
+	prev := sess.Status
+	sess.Status = status
+	sess.UpdatedAt = time.Now()
+	switch {
+	case status == StatusRunning && prev != StatusRunning:
+		sess.Stats.running(sess.UpdatedAt)
+	case status != StatusRunning && prev == StatusRunning:
+		sess.Stats.stopped(sess.UpdatedAt)
+	}
+	um.persistLocked(sess)
+	if prev != status {
+		um.events.publish(ManagerEvent{
+			Type:       ManagerEventStatus,
+			SessionID:  sess.ID,
+			GroupID:    sess.GroupID,
+			Client:     sess.Client,
+			Status:     status,
+			PrevStatus: prev,
+			Time:       sess.UpdatedAt,
+		})
+	}
+	um.finishLocked(sess)
+	if prev != status && (status == StatusFailed || status == StatusCancelled) {
+		um.cancelDependentsLocked(sess)
+	}
+}
+
+// observeCallbacks wraps the progress and event callbacks of a session's
+// upload config so that they are also published to subscribers.
+func (um *UploadManager) observeCallbacks(sess *UploadSession, cfg *Config) {
+	userProgress, userEvent := cfg.ProgressFunc, cfg.EventFunc
+	cfg.ProgressFunc = func(info ProgressInfo) {
+		um.mu.Lock()
+		um.chargeOwnerLocked(sess, info.Current-sess.Stats.BytesSent)
+		sess.Stats.progress(info, time.Now())
+		um.mu.Unlock()
+		um.events.publish(ManagerEvent{
+			Type:      ManagerEventProgress,
+			SessionID: sess.ID,
+			GroupID:   sess.GroupID,
+			Client:    sess.Client,
+			Progress:  &info,
+		})
+		if userProgress != nil {
+			userProgress(info)
+		}
+	}
+	cfg.EventFunc = func(info EventInfo) {
+		um.events.publish(ManagerEvent{
+			Type:      ManagerEventUpload,
+			SessionID: sess.ID,
+			GroupID:   sess.GroupID,
+			Client:    sess.Client,
+			Upload:    &info,
+		})
+		if userEvent != nil {
+			userEvent(info)
+		}
+	}
+}
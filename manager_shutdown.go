@@ -0,0 +1,119 @@
+// Package godav - Upload manager shutdown
+//
+// This file stops an UploadManager cleanly, e.g. on SIGTERM. Running uploads
+// finish the chunk in flight and stop with a checkpoint of their confirmed
+// chunks instead of being killed, so that RestoreSessions can continue them after a restart without
+// re-sending confirmed chunks.
+//
+// Features:
+//   - Stops scheduling, retries and the retention janitor
+//   - Stops running uploads at the next chunk boundary with a checkpoint
+//   - Waits for upload goroutines, bounded by a context
+//   - Summary of the sessions that must be resumed later
+package godav
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrManagerShutdown is returned when sessions are added to or started on an
+// UploadManager after Shutdown.
+var ErrManagerShutdown = errors.New("upload manager shut down")
+
+// ShutdownSummary describes the state an UploadManager was left in by
+// Shutdown.
+type ShutdownSummary struct {
+	Stopped []string         // Sessions whose running upload was stopped with a checkpoint
+	Forced  []string         // Sessions still uploading when ctx expired; their upload was aborted
+	Pending []*UploadSession // Copies of the unfinished sessions (queued or paused), in queue order
+}
+
+// Shutdown stops the manager. It stops starting sessions, lets running
+// uploads finish the chunk in flight, saves their checkpoints to the store
+// and waits for the upload goroutines to exit.
+//
+// Uploads that have not stopped when ctx expires are aborted, losing the
+// chunk in flight, and Shutdown returns ctx.Err().
+// Stopped sessions are left queued and paused sessions paused, so that
+// RestoreSessions in the next process continues them from their checkpoint.
+// After Shutdown, adding or starting sessions fails with ErrManagerShutdown.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	summary, err := manager.Shutdown(ctx)
+//	log.Printf("%d sessions to resume after restart (err: %v)", len(summary.Pending), err)
+func (um *UploadManager) Shutdown(ctx context.Context) (ShutdownSummary, error) {
+	var summary ShutdownSummary
+
+	um.mu.Lock()
+	um.closed = true
+	for _, timer := range []**time.Timer{&um.scheduleTimer, &um.janitorTimer} {
+		if *timer != nil {
+			(*timer).Stop()
+			*timer = nil
+		}
+	}
+	for _, sess := range um.sessions {
+		if sess.retryTimer != nil {
+			// Keep NextRetry so that the retry waits out its backoff after a restore
+			sess.retryTimer.Stop()
+			sess.retryTimer = nil
+		}
+		if !sess.running || sess.checkpointStop {
+			continue
+		}
+		sess.checkpointStop = true
+		if sess.Controller.State() == StatePaused {
+			// Waiting for a resume; its checkpoint was saved when it paused
+			sess.cancel()
+			continue
+		}
+		// Let the chunk in flight finish and stop once the checkpoint is
+		// saved at the next chunk boundary (see the CheckpointFunc wrapper
+		// in launchLocked)
+		sess.Controller.stopAtBoundary()
+		summary.Stopped = append(summary.Stopped, sess.ID)
+	}
+	um.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		um.uploads.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		um.mu.Lock()
+		for _, sess := range um.sessions {
+			if sess.running && sess.cancel != nil {
+				sess.cancel()
+				summary.Forced = append(summary.Forced, sess.ID)
+			}
+		}
+		um.mu.Unlock()
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	var pending []*UploadSession
+	for _, sess := range um.sessions {
+		if !isTerminal(sess.Status) {
+			um.persistLocked(sess)
+			pending = append(pending, sess)
+		}
+	}
+	sortQueue(pending)
+	for _, sess := range pending {
+		sessionCopy := *sess
+		summary.Pending = append(summary.Pending, &sessionCopy)
+	}
+	return summary, err
+}
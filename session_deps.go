@@ -0,0 +1,137 @@
+// Package godav - Session dependencies and completion hooks
+//
+// This file orders UploadManager sessions and acts on their results. A
+// session can depend on other sessions, e.g. a manifest that must only
+// appear once its data files are complete: it starts only after all of them
+// completed and is cancelled if one of them fails. Completion hooks run
+// after a successful upload, for server-side moves, share creation or local
+// callbacks that need the final remote path.
+//
+// Features:
+//   - Dependencies between sessions (start after success, cancel on failure)
+//   - Completion hooks run in order after a successful upload
+//   - Built-in move and share hooks
+package godav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrDependencyFailed is the cause of a session cancelled because a session
+// it depends on failed or was cancelled.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// CompletionInfo describes a completed upload to its completion hooks.
+// Hooks run in order and see the changes made by earlier hooks.
+type CompletionInfo struct {
+	SessionID  string   // Session that completed
+	LocalPath  string   // Uploaded local file
+	RemotePath string   // Current remote path, relative to the user's files (updated by MoveHook)
+	Skipped    bool     // The upload was skipped because the remote file was unchanged
+	Shares     []*Share // Shares created by ShareHook
+}
+
+// CompletionHook runs after a session's upload succeeded. An error fails the
+// session without retrying the upload; later hooks do not run.
+type CompletionHook func(ctx context.Context, c *Client, info *CompletionInfo) error
+
+// MoveHook moves the uploaded file to dstPath (relative to the user's files)
+// on the server, replacing an existing file, and creates missing parent
+// directories.
+//
+// Example (publish only complete files):
+//
+//	manager.AddUploadSessionWithOptions(local, "incoming/.report.pdf.part", client, godav.SessionOptions{
+//		Hooks: []godav.CompletionHook{godav.MoveHook("incoming/report.pdf")},
+//	})
+func MoveHook(dstPath string) CompletionHook {
+	return func(ctx context.Context, c *Client, info *CompletionInfo) error {
+		src, dst := c.toFilesPath(info.RemotePath), c.toFilesPath(dstPath)
+		if err := c.MkdirAll(c.dirOf(dst), 0o755); err != nil && !c.isAlreadyExists(err) {
+			return fmt.Errorf("move %s: create parent: %w", dstPath, err)
+		}
+		// The finalizing MOVE of other uploads adds headers under hdrMu
+		c.hdrMu.Lock()
+		err := c.Rename(src, dst, true)
+		c.hdrMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("move %s -> %s: %w", src, dst, err)
+		}
+		info.RemotePath = c.sanitizeRemotePath(dstPath)
+		return nil
+	}
+}
+
+// ShareHook shares the uploaded file (see Client.CreateShare) and records the
+// share in CompletionInfo.Shares for later hooks.
+//
+// Example:
+//
+//	hooks := []godav.CompletionHook{
+//		godav.ShareHook(godav.ShareOptions{ExpireDate: time.Now().AddDate(0, 0, 7)}),
+//		func(ctx context.Context, c *godav.Client, info *godav.CompletionInfo) error {
+//			return notify(info.RemotePath, info.Shares[0].URL)
+//		},
+//	}
+func ShareHook(opts ShareOptions) CompletionHook {
+	return func(ctx context.Context, c *Client, info *CompletionInfo) error {
+		share, err := c.CreateShare(ctx, info.RemotePath, opts)
+		if err != nil {
+			return err
+		}
+		info.Shares = append(info.Shares, share)
+		return nil
+	}
+}
+
+// runHooks runs the completion hooks of a completed upload.
+func runHooks(ctx context.Context, c *Client, hooks []CompletionHook, info *CompletionInfo) error {
+	for _, hook := range hooks {
+		if err := hook(ctx, c, info); err != nil {
+			return fmt.Errorf("completion hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkDependenciesLocked verifies that the sessions in ids exist.
+// um.mu must be held.
+func (um *UploadManager) checkDependenciesLocked(ids []string) error {
+	for _, id := range ids {
+		if _, exists := um.sessions[id]; !exists {
+			return fmt.Errorf("dependency %s not found", id)
+		}
+	}
+	return nil
+}
+
+// dependenciesMetLocked reports whether every dependency of sess completed.
+// Dependencies removed from the manager no longer block it.
+// um.mu must be held.
+func (um *UploadManager) dependenciesMetLocked(sess *UploadSession) bool {
+	for _, id := range sess.DependsOn {
+		if dep, exists := um.sessions[id]; exists && dep.Status != StatusCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+// cancelDependentsLocked cancels the unfinished sessions that depend on a
+// failed or cancelled session, and in turn their dependents.
+// um.mu must be held.
+func (um *UploadManager) cancelDependentsLocked(failed *UploadSession) {
+	for _, sess := range um.sessions {
+		if isTerminal(sess.Status) || sess.running || !slices.Contains(sess.DependsOn, failed.ID) {
+			continue
+		}
+		sess.Controller.Cancel()
+		sess.startRequested = false
+		sess.stopRetryLocked()
+		sess.Err = fmt.Errorf("%w: session %s %s", ErrDependencyFailed, failed.ID, failed.Status)
+		um.setStatusLocked(sess, StatusCancelled)
+	}
+}
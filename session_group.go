@@ -0,0 +1,401 @@
+// Package godav - Upload session groups
+//
+// This file groups UploadManager sessions that belong together, such as the
+// files of a directory or a batch, so that they can be controlled and
+// observed as one unit while the scheduler still uploads them file by file.
+//
+// Features:
+//   - Directory groups (one session per file) and explicit batches
+//   - Group status, aggregate progress and Done channel
+//   - Group-level start, pause, resume and cancel
+//   - Completion event once every member has finished
+package godav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// SessionGroup is a set of sessions managed as a unit. GetGroup returns a
+// copy with Status and Progress computed from the members.
+type SessionGroup struct {
+	ID         string         // Group ID
+	Name       string         // Display name (the local directory for directory groups)
+	SessionIDs []string       // Member sessions, in the order they were added
+	Status     UploadStatus   // Aggregate status of the members
+	Progress   ManagerSummary // Aggregate statistics of the members
+	CreatedAt  time.Time
+
+	done     chan struct{} // Closed once every member has finished
+	finished bool          // done has been closed
+}
+
+// BatchItem is a single file of a batch added with AddBatch.
+type BatchItem struct {
+	LocalPath  string // Local file path
+	RemotePath string // Remote destination path
+}
+
+// Done returns a channel that is closed once every session of the group has
+// finished (completed, failed or cancelled).
+func (g *SessionGroup) Done() <-chan struct{} {
+	return g.done
+}
+
+// AddBatch adds a session for each item, as one group. The sessions use the
+// given options and are scheduled like any other session.
+//
+// Example:
+//
+//	group, err := manager.AddBatch([]godav.BatchItem{
+//		{LocalPath: "/data/part1.bin", RemotePath: "Backups/part1.bin"},
+//		{LocalPath: "/data/part2.bin", RemotePath: "Backups/part2.bin"},
+//	}, client, godav.SessionOptions{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	_ = manager.StartGroup(group.ID)
+//	<-group.Done()
+func (um *UploadManager) AddBatch(items []BatchItem, client *Client, opts SessionOptions) (*SessionGroup, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("add batch: no items")
+	}
+	name := fmt.Sprintf("batch of %d files", len(items))
+	return um.addGroup(name, items, client, opts)
+}
+
+// AddDirectorySession scans localDir and adds a session for every regular
+// file below it, as one group, uploading to the same relative path below
+// remoteDir. The client's SkipHidden and SymlinkPolicy apply to the scan;
+// link-description files (SymlinkAsFile), special files and empty
+// directories are left out. Use Client.UploadDir to upload a directory
+// outside the manager.
+//
+// Example:
+//
+//	group, err := manager.AddDirectorySession("/data/photos", "Backups/photos", client, godav.SessionOptions{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	_ = manager.StartGroup(group.ID)
+//	if err := manager.WaitGroup(ctx, group.ID); err != nil {
+//		log.Printf("some files failed: %v", err)
+//	}
+func (um *UploadManager) AddDirectorySession(localDir, remoteDir string, client *Client, opts SessionOptions) (*SessionGroup, error) {
+	if client.config == nil {
+		client.config = DefaultConfig()
+	}
+	entries, err := client.scanDir(localDir)
+	if err != nil {
+		return nil, fmt.Errorf("scan %s: %w", localDir, err)
+	}
+
+	var items []BatchItem
+	for _, entry := range entries {
+		if entry.isDir || entry.skipReason != "" || entry.linkTarget != "" {
+			continue
+		}
+		items = append(items, BatchItem{
+			LocalPath:  entry.localPath,
+			RemotePath: path.Join(remoteDir, entry.relPath),
+		})
+	}
+	return um.addGroup(localDir, items, client, opts)
+}
+
+// addGroup creates a group holding a new session for each item.
+func (um *UploadManager) addGroup(name string, items []BatchItem, client *Client, opts SessionOptions) (*SessionGroup, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.closed {
+		return nil, ErrManagerShutdown
+	}
+	if err := um.checkDependenciesLocked(opts.DependsOn); err != nil {
+		return nil, fmt.Errorf("add group: %w", err)
+	}
+
+	um.seq++
+	group := &SessionGroup{
+		ID:        fmt.Sprintf("group-%d-%s", time.Now().UnixNano(), filepath.Base(name)),
+		Name:      name,
+		CreatedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	if _, exists := um.groups[group.ID]; exists {
+		group.ID = fmt.Sprintf("%s-%d", group.ID, um.seq)
+	}
+	um.groups[group.ID] = group
+
+	for _, item := range items {
+		sess := um.addSessionLocked(item.LocalPath, item.RemotePath, client, opts, group.ID)
+		group.SessionIDs = append(group.SessionIDs, sess.ID)
+	}
+	um.checkGroupLocked(group)
+	um.scheduleLocked()
+	return um.groupCopyLocked(group), nil
+}
+
+// restoreGroupMemberLocked adds a restored session to its group, recreating
+// the group if needed. um.mu must be held.
+func (um *UploadManager) restoreGroupMemberLocked(groupID, sessionID string, createdAt time.Time) {
+	group, exists := um.groups[groupID]
+	if !exists {
+		group = &SessionGroup{
+			ID:        groupID,
+			Name:      groupID,
+			CreatedAt: createdAt,
+			done:      make(chan struct{}),
+		}
+		um.groups[groupID] = group
+	}
+	group.SessionIDs = append(group.SessionIDs, sessionID)
+}
+
+// GetGroup returns a copy of a group with its current status and progress.
+func (um *UploadManager) GetGroup(groupID string) (*SessionGroup, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	group, exists := um.groups[groupID]
+	if !exists {
+		return nil, fmt.Errorf("group %s not found", groupID)
+	}
+	return um.groupCopyLocked(group), nil
+}
+
+// GetGroups returns copies of all groups.
+func (um *UploadManager) GetGroups() map[string]*SessionGroup {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	groups := make(map[string]*SessionGroup)
+	for id, group := range um.groups {
+		groups[id] = um.groupCopyLocked(group)
+	}
+	return groups
+}
+
+// GroupSessions returns copies of the sessions of a group.
+func (um *UploadManager) GroupSessions(groupID string) ([]*UploadSession, error) {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	group, exists := um.groups[groupID]
+	if !exists {
+		return nil, fmt.Errorf("group %s not found", groupID)
+	}
+	var sessions []*UploadSession
+	for _, sess := range um.membersLocked(group) {
+		sessionCopy := *sess
+		sessions = append(sessions, &sessionCopy)
+	}
+	return sessions, nil
+}
+
+// StartGroup starts every queued or paused session of the group. Sessions
+// beyond MaxConcurrent stay queued until the scheduler starts them.
+func (um *UploadManager) StartGroup(groupID string) error {
+	return um.forGroup(groupID, func(sess *UploadSession) bool {
+		return sess.Status == StatusQueued || sess.Status == StatusPaused
+	}, um.StartUpload)
+}
+
+// PauseGroup pauses every running session of the group and holds its queued
+// sessions until ResumeGroup (see PauseAllUploads).
+func (um *UploadManager) PauseGroup(groupID string) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, exists := um.groups[groupID]; !exists {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	um.setGateLocked(func(g *pauseGate) { g.groups[groupID] = true })
+	um.pauseMatchingLocked(func(sess *UploadSession) bool { return sess.GroupID == groupID })
+	return nil
+}
+
+// ResumeGroup lifts PauseGroup and resumes every paused session of the group.
+func (um *UploadManager) ResumeGroup(groupID string) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if _, exists := um.groups[groupID]; !exists {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	um.setGateLocked(func(g *pauseGate) { delete(g.groups, groupID) })
+	um.resumeMatchingLocked(func(sess *UploadSession) bool { return sess.GroupID == groupID })
+	return nil
+}
+
+// CancelGroup cancels every unfinished session of the group.
+func (um *UploadManager) CancelGroup(groupID string) error {
+	return um.forGroup(groupID, func(sess *UploadSession) bool {
+		return !isTerminal(sess.Status)
+	}, um.CancelUpload)
+}
+
+// forGroup applies op to the members of a group selected by match. Members
+// whose status changes in between are reported in the returned error.
+func (um *UploadManager) forGroup(groupID string, match func(*UploadSession) bool, op func(string) error) error {
+	um.mu.RLock()
+	group, exists := um.groups[groupID]
+	if !exists {
+		um.mu.RUnlock()
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	var ids []string
+	for _, sess := range um.membersLocked(group) {
+		if match(sess) {
+			ids = append(ids, sess.ID)
+		}
+	}
+	um.mu.RUnlock()
+
+	var errs []error
+	for _, id := range ids {
+		if err := op(id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WaitGroup blocks until every session of the group has finished or ctx is
+// done. It returns nil if every session completed, the joined failure causes
+// otherwise, and ctx.Err() if ctx ends first.
+func (um *UploadManager) WaitGroup(ctx context.Context, groupID string) error {
+	um.mu.RLock()
+	group, exists := um.groups[groupID]
+	um.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("group %s not found", groupID)
+	}
+
+	select {
+	case <-group.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+	var errs []error
+	for _, sess := range um.membersLocked(group) {
+		if sess.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sess.LocalPath, sess.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveGroup removes a group and its sessions. It fails if any session of
+// the group is still running.
+func (um *UploadManager) RemoveGroup(groupID string) error {
+	um.mu.Lock()
+	group, exists := um.groups[groupID]
+	if !exists {
+		um.mu.Unlock()
+		return fmt.Errorf("group %s not found", groupID)
+	}
+	for _, sess := range um.membersLocked(group) {
+		if sess.Status == StatusRunning {
+			um.mu.Unlock()
+			return fmt.Errorf("cannot remove group %s: session %s is running", groupID, sess.ID)
+		}
+	}
+	delete(um.groups, groupID)
+	ids := group.SessionIDs
+	um.mu.Unlock()
+
+	var errs []error
+	for _, id := range ids {
+		if err := um.RemoveUploadSession(id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// membersLocked returns the group's sessions still known to the manager.
+// um.mu must be held.
+func (um *UploadManager) membersLocked(group *SessionGroup) []*UploadSession {
+	members := make([]*UploadSession, 0, len(group.SessionIDs))
+	for _, id := range group.SessionIDs {
+		if sess, ok := um.sessions[id]; ok {
+			members = append(members, sess)
+		}
+	}
+	return members
+}
+
+// groupCopyLocked returns a copy of group with computed status and progress.
+// um.mu must be held.
+func (um *UploadManager) groupCopyLocked(group *SessionGroup) *SessionGroup {
+	members := um.membersLocked(group)
+	groupCopy := *group
+	groupCopy.SessionIDs = append([]string(nil), group.SessionIDs...)
+	groupCopy.Status = groupStatus(members)
+	groupCopy.Progress = summarize(members)
+	return &groupCopy
+}
+
+// groupStatus derives a group's status from its members: running while any
+// member runs, then queued, then paused; once all have finished, failed if
+// any failed, cancelled if all were cancelled and completed otherwise.
+func groupStatus(members []*UploadSession) UploadStatus {
+	counts := make(map[UploadStatus]int)
+	for _, sess := range members {
+		counts[sess.Status]++
+	}
+	switch {
+	case counts[StatusRunning] > 0:
+		return StatusRunning
+	case counts[StatusQueued] > 0:
+		return StatusQueued
+	case counts[StatusPaused] > 0:
+		return StatusPaused
+	case counts[StatusFailed] > 0:
+		return StatusFailed
+	case len(members) > 0 && counts[StatusCancelled] == len(members):
+		return StatusCancelled
+	default:
+		return StatusCompleted
+	}
+}
+
+// finishLocked closes the session's done channel once it has finished and
+// completes its group when it was the last member. um.mu must be held.
+func (um *UploadManager) finishLocked(sess *UploadSession) {
+	sess.finishLocked()
+	if sess.finished && sess.GroupID != "" {
+		if group, ok := um.groups[sess.GroupID]; ok {
+			um.checkGroupLocked(group)
+		}
+	}
+}
+
+// checkGroupLocked completes the group once every member has finished,
+// publishing ManagerEventGroupComplete. um.mu must be held.
+func (um *UploadManager) checkGroupLocked(group *SessionGroup) {
+	if group.finished {
+		return
+	}
+	members := um.membersLocked(group)
+	for _, sess := range members {
+		if !sess.finished {
+			return
+		}
+	}
+	group.finished = true
+	close(group.done)
+	um.events.publish(ManagerEvent{
+		Type:    ManagerEventGroupComplete,
+		GroupID: group.ID,
+		Status:  groupStatus(members),
+	})
+}
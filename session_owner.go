@@ -0,0 +1,303 @@
+// Package godav - Per-owner fairness and quotas
+//
+// This file keeps one busy owner (a user or tenant of a shared
+// UploadManager) from starving the others. Sessions are grouped by an owner
+// key; each owner can be limited in concurrent uploads, upload rate and
+// bytes per day, and the scheduler can hand out free slots round-robin or
+// in proportion to owner weights instead of in global queue order.
+//
+// Features:
+//   - Owner key per session
+//   - Per-owner concurrency, byte-rate and daily byte quota limits
+//   - Round-robin and weighted fair scheduling across owners
+//   - Pause with checkpoint when a quota is used up, resume the next day
+package godav
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fairness selects how the scheduler shares free slots between owners
+type Fairness int
+
+const (
+	FairnessNone       Fairness = iota // Global priority/FIFO order (default)
+	FairnessRoundRobin                 // Owners take turns; priority/FIFO order within an owner
+	FairnessWeighted                   // Owners get slots in proportion to OwnerLimits.Weight
+)
+
+// OwnerLimits limits the sessions of one owner. Zero values mean unlimited.
+type OwnerLimits struct {
+	// MaxConcurrent limits how many sessions of the owner upload at once.
+	MaxConcurrent int
+
+	// BytesPerSecond limits the combined upload rate of the owner's
+	// sessions. The limit is applied per chunk, so it is met on average.
+	BytesPerSecond int64
+
+	// DailyQuota limits the bytes the owner may upload per local calendar
+	// day. When it is used up, running sessions are paused at the next chunk
+	// boundary (exceeding it by at most one chunk each) and resumed the next day.
+	DailyQuota int64
+
+	// Weight is the owner's share of slots with FairnessWeighted.
+	// Default: 1.
+	Weight int
+}
+
+// OwnerUsage reports the current activity and quota use of an owner.
+type OwnerUsage struct {
+	Owner          string // Owner key
+	Active         int    // Sessions with a running upload
+	Queued         int    // Sessions waiting to start
+	BytesToday     int64  // Bytes uploaded today
+	QuotaRemaining int64  // Bytes left in today's quota (-1 if unlimited)
+}
+
+// ownerState is the manager's bookkeeping for one owner.
+type ownerState struct {
+	limits  *OwnerLimits // Owner-specific limits (nil uses ManagerConfig.OwnerLimits)
+	active  int          // Sessions with a running upload goroutine
+	served  int64        // When the owner was last given a slot, for round-robin
+	day     string       // Local date of used
+	used    int64        // Bytes uploaded on day
+	limiter *rateLimiter // Shared byte-rate limiter of the owner's sessions
+}
+
+// SetOwnerLimits sets the limits of one owner, replacing
+// ManagerConfig.OwnerLimits for it. Changes apply to running sessions.
+//
+// Example:
+//
+//	manager.SetOwnerLimits("alice", godav.OwnerLimits{
+//		MaxConcurrent:  2,
+//		BytesPerSecond: 5 << 20,  // 5 MB/s
+//		DailyQuota:     50 << 30, // 50 GB
+//		Weight:         2,
+//	})
+func (um *UploadManager) SetOwnerLimits(owner string, limits OwnerLimits) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	o := um.ownerLocked(owner)
+	o.limits = &limits
+	o.limiter.setRate(limits.BytesPerSecond)
+	um.scheduleLocked()
+}
+
+// OwnerUsage returns the current activity and quota use of an owner.
+func (um *UploadManager) OwnerUsage(owner string) OwnerUsage {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	o := um.ownerLocked(owner)
+	usage := OwnerUsage{
+		Owner:          owner,
+		Active:         o.active,
+		BytesToday:     o.usedOn(time.Now()),
+		QuotaRemaining: -1,
+	}
+	for _, sess := range um.sessions {
+		if sess.Owner == owner && sess.Status == StatusQueued {
+			usage.Queued++
+		}
+	}
+	if quota := um.limitsOf(o).DailyQuota; quota > 0 {
+		usage.QuotaRemaining = max(quota-usage.BytesToday, 0)
+	}
+	return usage
+}
+
+// ownerLocked returns the state of an owner, creating it if needed.
+// um.mu must be held.
+func (um *UploadManager) ownerLocked(owner string) *ownerState {
+	o, ok := um.owners[owner]
+	if !ok {
+		o = &ownerState{limiter: &rateLimiter{}}
+		o.limiter.setRate(um.config.OwnerLimits.BytesPerSecond)
+		um.owners[owner] = o
+	}
+	return o
+}
+
+// limitsOf returns the limits applying to o.
+func (um *UploadManager) limitsOf(o *ownerState) OwnerLimits {
+	if o.limits != nil {
+		return *o.limits
+	}
+	return um.config.OwnerLimits
+}
+
+// usedOn returns the bytes uploaded on the local day of now, starting a new
+// day if needed.
+func (o *ownerState) usedOn(now time.Time) int64 {
+	if day := now.Format(time.DateOnly); day != o.day {
+		o.day = day
+		o.used = 0
+	}
+	return o.used
+}
+
+// quotaLeftLocked reports whether the owner may upload more today.
+// um.mu must be held.
+func (um *UploadManager) quotaLeftLocked(o *ownerState, now time.Time) bool {
+	quota := um.limitsOf(o).DailyQuota
+	return quota <= 0 || o.usedOn(now) < quota
+}
+
+// ownerAllowsLocked reports whether the owner may start another session.
+// um.mu must be held.
+func (um *UploadManager) ownerAllowsLocked(o *ownerState, now time.Time) bool {
+	limits := um.limitsOf(o)
+	if limits.MaxConcurrent > 0 && o.active >= limits.MaxConcurrent {
+		return false
+	}
+	return um.quotaLeftLocked(o, now)
+}
+
+// nextWaitingLocked removes and returns the session to start next from
+// waiting (sorted by priority and queue position), honoring owner limits and
+// the fairness mode. It returns nil if no waiting session may start.
+// um.mu must be held.
+func (um *UploadManager) nextWaitingLocked(waiting *[]*UploadSession, now time.Time) *UploadSession {
+	// The first eligible session of each owner, in queue order
+	best := -1
+	var bestOwner *ownerState
+	seen := make(map[string]bool)
+	for i, sess := range *waiting {
+		if seen[sess.Owner] {
+			continue
+		}
+		seen[sess.Owner] = true
+		o := um.ownerLocked(sess.Owner)
+		if !um.ownerAllowsLocked(o, now) {
+			continue
+		}
+		if best < 0 || um.preferOwnerLocked(o, bestOwner) {
+			best, bestOwner = i, o
+		}
+		if um.config.Fairness == FairnessNone {
+			break
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+
+	sess := (*waiting)[best]
+	*waiting = append((*waiting)[:best], (*waiting)[best+1:]...)
+	um.serveSeq++
+	bestOwner.served = um.serveSeq
+	return sess
+}
+
+// preferOwnerLocked reports whether owner a should get the next slot before
+// owner b under the fairness mode. um.mu must be held.
+func (um *UploadManager) preferOwnerLocked(a, b *ownerState) bool {
+	if um.config.Fairness == FairnessWeighted {
+		wa, wb := max(um.limitsOf(a).Weight, 1), max(um.limitsOf(b).Weight, 1)
+		// Compare active/weight without dividing
+		if la, lb := a.active*wb, b.active*wa; la != lb {
+			return la < lb
+		}
+	}
+	return a.served < b.served
+}
+
+// chargeOwnerLocked records bytes uploaded by a session and pauses the
+// owner's running sessions once its daily quota is used up.
+// um.mu must be held.
+func (um *UploadManager) chargeOwnerLocked(sess *UploadSession, n int64) {
+	if n <= 0 {
+		return
+	}
+	now := time.Now()
+	o := um.ownerLocked(sess.Owner)
+	o.usedOn(now)
+	o.used += n
+	if um.quotaLeftLocked(o, now) {
+		return
+	}
+	for _, other := range um.sessions {
+		if other.Owner == sess.Owner && other.Status == StatusRunning {
+			// Stop once the checkpoint is saved
+			other.PausedByQuota = true
+			other.checkpointStop = true
+			other.Controller.Pause()
+			um.setStatusLocked(other, StatusPaused)
+		}
+	}
+}
+
+// applyQuotasLocked queues sessions paused by their owner's quota once the
+// owner has quota left again. um.mu must be held.
+func (um *UploadManager) applyQuotasLocked(now time.Time) {
+	for _, sess := range um.sessions {
+		if sess.Status == StatusPaused && sess.PausedByQuota && !sess.running && um.quotaLeftLocked(um.ownerLocked(sess.Owner), now) {
+			sess.PausedByQuota = false
+			sess.Controller.Resume()
+			um.queueStartLocked(sess)
+		}
+	}
+}
+
+// nextQuotaResetLocked returns the next local midnight if a session waits
+// for its owner's quota, or the zero time. um.mu must be held.
+func (um *UploadManager) nextQuotaResetLocked(now time.Time) time.Time {
+	for _, sess := range um.sessions {
+		waiting := sess.PausedByQuota || (sess.Status == StatusQueued && sess.startRequested)
+		if waiting && !um.quotaLeftLocked(um.ownerLocked(sess.Owner), now) {
+			y, m, d := now.Date()
+			return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+		}
+	}
+	return time.Time{}
+}
+
+// rateLimiter paces uploads to a byte rate shared by several sessions.
+type rateLimiter struct {
+	rate int64     // Bytes per second (0 = unlimited)
+	next time.Time // When the next reservation may start
+	mu   sync.Mutex
+}
+
+func (l *rateLimiter) setRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+}
+
+// wait blocks until n more bytes may be sent, or ctx is done. A nil limiter
+// never blocks.
+func (l *rateLimiter) wait(ctx context.Context, n int64) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	l.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
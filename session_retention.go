@@ -0,0 +1,212 @@
+// Package godav - Upload session retention
+//
+// This file keeps long-running UploadManagers from accumulating finished
+// sessions forever. A retention policy removes completed, failed and
+// cancelled sessions by age and by count per status, from a background
+// janitor, and RemoveSessions removes any set of sessions at once. Every
+// removal is published to subscribers so that UIs can drop the session.
+//
+// Features:
+//   - Maximum age of finished sessions
+//   - Maximum number of finished sessions per status (oldest removed first)
+//   - Background janitor with a configurable interval
+//   - Bulk removal by filter and a removal event
+package godav
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DefaultRetentionInterval is how often the janitor runs when
+// RetentionPolicy.Interval is not positive.
+const DefaultRetentionInterval = time.Minute
+
+// RetentionPolicy controls how long finished sessions (completed, failed or
+// cancelled) are kept by an UploadManager. Removed sessions are also deleted
+// from the session store.
+//
+// Example:
+//
+//	cfg := godav.DefaultManagerConfig()
+//	cfg.Retention = godav.RetentionPolicy{
+//		MaxAge: 24 * time.Hour,
+//		MaxCount: map[godav.UploadStatus]int{
+//			godav.StatusCompleted: 100,
+//			godav.StatusFailed:    500,
+//		},
+//	}
+//	manager := godav.NewUploadManagerWithConfig(cfg)
+type RetentionPolicy struct {
+	// MaxAge removes sessions that finished longer ago. 0 keeps them
+	// regardless of age.
+	MaxAge time.Duration
+
+	// MaxCount limits the number of finished sessions kept per status;
+	// the oldest are removed first. Statuses without an entry are not limited.
+	MaxCount map[UploadStatus]int
+
+	// Interval is how often the janitor applies the policy.
+	// Default: DefaultRetentionInterval.
+	Interval time.Duration
+}
+
+// enabled reports whether the policy removes anything.
+func (p *RetentionPolicy) enabled() bool {
+	return p.MaxAge > 0 || len(p.MaxCount) > 0
+}
+
+// SessionFilter selects sessions for RemoveSessions. Empty fields match
+// everything.
+type SessionFilter struct {
+	IDs            []string       // Only these sessions
+	Statuses       []UploadStatus // Only sessions with one of these statuses
+	Client         *Client        // Only sessions of this client
+	Owner          *string        // Only sessions of this owner (nil for any owner)
+	GroupID        string         // Only sessions of this group
+	FinishedBefore time.Time      // Only sessions that finished before this time
+}
+
+// match reports whether sess passes the filter.
+func (f *SessionFilter) match(sess *UploadSession) bool {
+	switch {
+	case len(f.IDs) > 0 && !slices.Contains(f.IDs, sess.ID):
+		return false
+	case len(f.Statuses) > 0 && !slices.Contains(f.Statuses, sess.Status):
+		return false
+	case f.Client != nil && sess.Client != f.Client:
+		return false
+	case f.Owner != nil && sess.Owner != *f.Owner:
+		return false
+	case f.GroupID != "" && sess.GroupID != f.GroupID:
+		return false
+	case !f.FinishedBefore.IsZero() && (!isTerminal(sess.Status) || !sess.UpdatedAt.Before(f.FinishedBefore)):
+		return false
+	}
+	return true
+}
+
+// RemoveSessions removes every session matching filter, except sessions
+// with a running upload, and returns the IDs of the removed sessions.
+// Removing queued or paused sessions drops them without cancelling their
+// uploaded chunks; use CancelUpload first to clean those up.
+//
+// Example:
+//
+//	// Clear the failed sessions of one user
+//	owner := "alice"
+//	removed, err := manager.RemoveSessions(godav.SessionFilter{
+//		Owner:    &owner,
+//		Statuses: []godav.UploadStatus{godav.StatusFailed},
+//	})
+func (um *UploadManager) RemoveSessions(filter SessionFilter) ([]string, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	var matched []*UploadSession
+	for _, sess := range um.sessions {
+		if !sess.running && sess.Status != StatusRunning && filter.match(sess) {
+			matched = append(matched, sess)
+		}
+	}
+	return um.removeSessionsLocked(matched)
+}
+
+// PruneSessions applies the retention policy immediately and returns the
+// IDs of the removed sessions. The janitor does the same periodically.
+func (um *UploadManager) PruneSessions() ([]string, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	return um.pruneLocked(time.Now())
+}
+
+// pruneLocked removes the finished sessions the retention policy no longer
+// keeps. um.mu must be held.
+func (um *UploadManager) pruneLocked(now time.Time) ([]string, error) {
+	policy := &um.config.Retention
+	byStatus := make(map[UploadStatus][]*UploadSession)
+	var expired []*UploadSession
+	for _, sess := range um.sessions {
+		if !isTerminal(sess.Status) || sess.running {
+			continue
+		}
+		if policy.MaxAge > 0 && now.Sub(sess.UpdatedAt) > policy.MaxAge {
+			expired = append(expired, sess)
+			continue
+		}
+		byStatus[sess.Status] = append(byStatus[sess.Status], sess)
+	}
+	for status, sessions := range byStatus {
+		limit, ok := policy.MaxCount[status]
+		if !ok || len(sessions) <= limit {
+			continue
+		}
+		// Newest first; everything past the limit goes
+		sort.Slice(sessions, func(i, j int) bool {
+			return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+		})
+		expired = append(expired, sessions[max(limit, 0):]...)
+	}
+	return um.removeSessionsLocked(expired)
+}
+
+// removeSessionsLocked removes sessions and returns their IDs.
+// um.mu must be held.
+func (um *UploadManager) removeSessionsLocked(sessions []*UploadSession) ([]string, error) {
+	sortQueue(sessions) // Deterministic order for events and results
+	ids := make([]string, 0, len(sessions))
+	var errs []error
+	for _, sess := range sessions {
+		if err := um.removeSessionLocked(sess); err != nil {
+			errs = append(errs, err)
+		}
+		ids = append(ids, sess.ID)
+	}
+	return ids, errors.Join(errs...)
+}
+
+// removeSessionLocked removes a session without a running upload from the
+// manager and its store, and publishes ManagerEventRemoved.
+// um.mu must be held.
+func (um *UploadManager) removeSessionLocked(sess *UploadSession) error {
+	sess.stopRetryLocked()
+	delete(um.sessions, sess.ID)
+	if group, ok := um.groups[sess.GroupID]; ok {
+		um.checkGroupLocked(group)
+	}
+	um.events.publish(ManagerEvent{
+		Type:      ManagerEventRemoved,
+		SessionID: sess.ID,
+		GroupID:   sess.GroupID,
+		Client:    sess.Client,
+		Status:    sess.Status,
+	})
+	if um.config.Store != nil {
+		return um.config.Store.Delete(sess.ID)
+	}
+	return nil
+}
+
+// armJanitorLocked schedules the next run of the retention janitor, if the
+// policy is enabled. um.mu must be held.
+func (um *UploadManager) armJanitorLocked() {
+	if !um.config.Retention.enabled() {
+		return
+	}
+	interval := um.config.Retention.Interval
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	um.janitorTimer = time.AfterFunc(interval, func() {
+		um.mu.Lock()
+		defer um.mu.Unlock()
+		if um.janitorTimer == nil {
+			return // Stopped
+		}
+		_, _ = um.pruneLocked(time.Now())
+		um.armJanitorLocked()
+	})
+}
@@ -0,0 +1,167 @@
+// Package godav - Upload session retry policy
+//
+// This file lets an UploadManager retry failed sessions on its own, e.g. when
+// the server restarts overnight. A failed attempt puts the session back in the
+// queue with a backoff delay; the next attempt resumes from the session's
+// latest checkpoint when the local file is unchanged.
+//
+// Features:
+//   - Maximum attempts and exponential backoff with a cap
+//   - Pluggable classification of retryable errors
+//   - Manager-wide default with per-session overrides
+//   - Retry attempts visible in session state and events
+package godav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"math"
+	"time"
+)
+
+// Retry backoff defaults, used when the RetryPolicy fields are zero.
+const (
+	DefaultRetryInitialBackoff = 10 * time.Second
+	DefaultRetryMaxBackoff     = 10 * time.Minute
+	DefaultRetryMultiplier     = 2.0
+)
+
+// RetryPolicy controls how failed sessions are retried. Chunk-level retries
+// (Config.MaxRetries) happen first, within each attempt.
+//
+// Example:
+//
+//	cfg := godav.DefaultManagerConfig()
+//	cfg.Retry = godav.RetryPolicy{
+//		MaxAttempts:    5,
+//		InitialBackoff: 30 * time.Second,
+//		MaxBackoff:     30 * time.Minute,
+//	}
+//	manager := godav.NewUploadManagerWithConfig(cfg)
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per session, including the
+	// first one. 0 or 1 disables retries (default).
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry.
+	// Default: DefaultRetryInitialBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.
+	// Default: DefaultRetryMaxBackoff.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the delay after each failed attempt.
+	// Default: DefaultRetryMultiplier.
+	Multiplier float64
+
+	// Retryable decides whether a failed attempt is retried.
+	// Default: DefaultRetryable.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryable retries every error except cancellations and local file
+// errors (missing file, permission denied), which another attempt cannot fix.
+func DefaultRetryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrUploadCancelled), errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return false
+	}
+	return true
+}
+
+// allows reports whether a session whose failures-th attempt failed with err
+// may be retried.
+func (p *RetryPolicy) allows(failures int, err error) bool {
+	if failures >= p.MaxAttempts {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	return retryable(err)
+}
+
+// backoff returns the delay after the failures-th failed attempt.
+func (p *RetryPolicy) backoff(failures int) time.Duration {
+	initial, maxDelay, mult := p.InitialBackoff, p.MaxBackoff, p.Multiplier
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxBackoff
+	}
+	if mult < 1 {
+		mult = DefaultRetryMultiplier
+	}
+	delay := float64(initial) * math.Pow(mult, float64(failures-1))
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+	return time.Duration(delay)
+}
+
+// retryPolicy returns the policy applying to sess.
+func (um *UploadManager) retryPolicy(sess *UploadSession) *RetryPolicy {
+	if sess.retry != nil {
+		return sess.retry
+	}
+	return &um.config.Retry
+}
+
+// retryLocked counts a failed attempt and queues the session for another
+// attempt after its backoff delay, if the retry policy allows it. Relaunches
+// after a pause, schedule window or shutdown are not failures and do not use
+// up attempts. um.mu must be held.
+func (um *UploadManager) retryLocked(sess *UploadSession, err error) bool {
+	sess.Stats.Failures++
+	policy := um.retryPolicy(sess)
+	failures := sess.Stats.Failures
+	if !policy.allows(failures, err) {
+		return false
+	}
+
+	delay := policy.backoff(failures)
+	sess.NextRetry = time.Now().Add(delay)
+	sess.startRequested = true
+	um.setStatusLocked(sess, StatusQueued)
+	um.events.publish(ManagerEvent{
+		Type:      ManagerEventRetry,
+		SessionID: sess.ID,
+		GroupID:   sess.GroupID,
+		Client:    sess.Client,
+		Status:    StatusQueued,
+		Err:       err,
+		Attempt:   failures,
+		RetryAt:   sess.NextRetry,
+	})
+	if sess.Config.Verbose {
+		log.Printf("session %s: attempt %d failed, retrying in %s: %v", sess.ID, failures, delay, err)
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		um.mu.Lock()
+		defer um.mu.Unlock()
+		if sess.retryTimer == timer {
+			sess.retryTimer = nil
+			um.scheduleLocked()
+		}
+	})
+	sess.retryTimer = timer
+	return true
+}
+
+// stopRetryLocked cancels a pending retry of sess. um.mu must be held.
+func (sess *UploadSession) stopRetryLocked() {
+	if sess.retryTimer != nil {
+		sess.retryTimer.Stop()
+		sess.retryTimer = nil
+	}
+	sess.NextRetry = time.Time{}
+}
@@ -0,0 +1,225 @@
+// Package godav - Upload time windows and scheduled starts
+//
+// This file restricts when UploadManager sessions may upload. A session can
+// have a "not before" time and recurring daily windows (e.g. weekdays
+// 20:00–06:00). Outside its windows a session is not started; a running
+// session is paused with a checkpoint when its window closes, releasing its
+// slot, and resumed automatically from that checkpoint when it reopens.
+//
+// Features:
+//   - Not-before start times
+//   - Recurring windows by weekday, spanning midnight if needed
+//   - Pause with checkpoint on close, automatic resume on reopen
+//   - Manager-wide default with per-session overrides
+package godav
+
+import (
+	"slices"
+	"time"
+)
+
+// TimeWindow is a recurring daily period during which uploads may run.
+//
+// Example (weekdays 20:00–06:00, local time):
+//
+//	godav.TimeWindow{
+//		Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+//		Start: 20 * time.Hour,
+//		End:   6 * time.Hour,
+//	}
+type TimeWindow struct {
+	// Days on which the window opens. A window spanning midnight stays open
+	// into the next day. Empty means every day.
+	Days []time.Weekday
+
+	// Start is the time of day the window opens, as an offset from midnight.
+	Start time.Duration
+
+	// End is the time of day the window closes, as an offset from midnight.
+	// An End not after Start closes the window on the next day.
+	End time.Duration
+
+	// Location is the time zone of Start and End.
+	// Default: time.Local.
+	Location *time.Location
+}
+
+// UploadSchedule restricts when a session may upload.
+type UploadSchedule struct {
+	// NotBefore delays the first start of the session. Zero means no delay.
+	NotBefore time.Time
+
+	// Windows lists the periods during which the session may upload.
+	// Empty means at any time.
+	Windows []TimeWindow
+}
+
+// clone returns a deep copy of s, so that the caller's schedule can be
+// changed without affecting the sessions it was given to. A nil schedule
+// stays nil.
+func (s *UploadSchedule) clone() *UploadSchedule {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Windows = slices.Clone(s.Windows)
+	for i := range c.Windows {
+		c.Windows[i].Days = slices.Clone(c.Windows[i].Days)
+	}
+	return &c
+}
+
+// occurrence returns the open and close times of the window opening on the
+// day of t, and whether it opens on that day.
+func (w *TimeWindow) occurrence(t time.Time) (time.Time, time.Time, bool) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	if len(w.Days) > 0 && !slices.Contains(w.Days, midnight.Weekday()) {
+		return time.Time{}, time.Time{}, false
+	}
+	open := midnight.Add(w.Start)
+	closeAt := midnight.Add(w.End)
+	if w.End <= w.Start {
+		closeAt = closeAt.AddDate(0, 0, 1)
+	}
+	return open, closeAt, true
+}
+
+// contains reports whether the window is open at t.
+func (w *TimeWindow) contains(t time.Time) bool {
+	// The window that opened yesterday may still be open
+	for _, day := range []time.Time{t, t.AddDate(0, 0, -1)} {
+		if open, closeAt, ok := w.occurrence(day); ok && !t.Before(open) && t.Before(closeAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextChange returns the first time after t at which the window opens or
+// closes, or the zero time if it never does.
+func (w *TimeWindow) nextChange(t time.Time) time.Time {
+	var next time.Time
+	for d := -1; d <= 7; d++ {
+		open, closeAt, ok := w.occurrence(t.AddDate(0, 0, d))
+		if !ok {
+			continue
+		}
+		for _, at := range []time.Time{open, closeAt} {
+			if at.After(t) && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+	}
+	return next
+}
+
+// allows reports whether a session with the schedule may upload at t.
+func (s *UploadSchedule) allows(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	if t.Before(s.NotBefore) {
+		return false
+	}
+	if len(s.Windows) == 0 {
+		return true
+	}
+	for i := range s.Windows {
+		if s.Windows[i].contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextChange returns the first time after t at which allows may change, or
+// the zero time if it never does.
+func (s *UploadSchedule) nextChange(t time.Time) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	if t.Before(s.NotBefore) {
+		return s.NotBefore
+	}
+	var next time.Time
+	for i := range s.Windows {
+		if at := s.Windows[i].nextChange(t); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return next
+}
+
+// SetSchedule changes the schedule of a session that has not finished. A nil
+// schedule lets the session upload at any time. The session keeps a copy, so
+// later changes to schedule have no effect.
+func (um *UploadManager) SetSchedule(sessionID string, schedule *UploadSchedule) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	session, err := um.unfinishedSessionLocked(sessionID)
+	if err != nil {
+		return err
+	}
+	session.Schedule = schedule.clone()
+	um.scheduleLocked()
+	return nil
+}
+
+// applySchedulesLocked pauses running sessions whose window has closed and
+// queues sessions paused by their schedule whose window has reopened.
+// um.mu must be held.
+func (um *UploadManager) applySchedulesLocked(now time.Time) {
+	for _, sess := range um.sessions {
+		if sess.Schedule == nil {
+			continue
+		}
+		allowed := sess.Schedule.allows(now)
+		switch {
+		case sess.Status == StatusRunning && !allowed:
+			// Stop once the checkpoint is saved,
+			// releasing the slot (see the CheckpointFunc wrapper in launchLocked)
+			sess.PausedBySchedule = true
+			sess.checkpointStop = true
+			sess.Controller.Pause()
+			um.setStatusLocked(sess, StatusPaused)
+		case sess.Status == StatusPaused && sess.PausedBySchedule && !sess.running && allowed:
+			sess.PausedBySchedule = false
+			sess.Controller.Resume()
+			um.queueStartLocked(sess)
+		}
+	}
+}
+
+// armScheduleTimerLocked wakes the scheduler at the next time a session's
+// schedule changes or, if a session waits for its owner's quota, at the next
+// quota reset. um.mu must be held.
+func (um *UploadManager) armScheduleTimerLocked(now time.Time) {
+	next := um.nextQuotaResetLocked(now)
+	for _, sess := range um.sessions {
+		if sess.Schedule == nil || isTerminal(sess.Status) {
+			continue
+		}
+		if at := sess.Schedule.nextChange(now); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+
+	if um.scheduleTimer != nil {
+		um.scheduleTimer.Stop()
+		um.scheduleTimer = nil
+	}
+	if next.IsZero() {
+		return
+	}
+	um.scheduleTimer = time.AfterFunc(next.Sub(now), func() {
+		um.mu.Lock()
+		defer um.mu.Unlock()
+		um.scheduleLocked()
+	})
+}
@@ -0,0 +1,204 @@
+// Package godav - Upload session statistics
+//
+// This file keeps live statistics on every UploadManager session, fed by the
+// upload's progress callbacks, so that the manager can report how far along a
+// session is without the application tracking callbacks itself.
+//
+// Features:
+//   - Bytes and chunks sent, percentage and totals per session
+//   - Current (smoothed) and average throughput, ETA
+//   - Attempt and failure counts, last error
+//   - Manager-wide summary across all sessions
+package godav
+
+import (
+	"os"
+	"time"
+)
+
+// speedSmoothing is the weight of the latest chunk in CurrentSpeed.
+const speedSmoothing = 0.3
+
+// SessionStats holds live statistics of an upload session. It is updated as
+// chunks are confirmed by the server and copied with the session by
+// GetUploadSession and GetUploadSessions.
+type SessionStats struct {
+	BytesSent    int64         // Bytes confirmed by the server, including earlier attempts
+	BytesTotal   int64         // File size in bytes
+	ChunksDone   int           // Chunks confirmed by the server
+	ChunksTotal  int           // Total number of chunks (0 until the upload starts)
+	Percentage   float64       // Progress by bytes (0.0 to 100.0)
+	CurrentSpeed float64       // Recent throughput in bytes per second (0 when not running)
+	AverageSpeed float64       // Average throughput of the current attempt while running
+	ETA          time.Duration // Estimated time remaining (0 when unknown or not running)
+	Attempts     int           // Number of times the upload was started, including resumes after a pause
+	Failures     int           // Number of attempts that failed (counted by the retry policy)
+	LastError    error         // Most recent upload error (nil if none)
+	StartedAt    time.Time     // When the current attempt started
+	UpdatedAt    time.Time     // Last progress update
+
+	attemptBase  int64         // BytesSent when the current attempt started
+	activeTime   time.Duration // Running time of the current attempt, excluding pauses
+	runningSince time.Time     // Start of the current running period (zero while stopped)
+	lastSample   time.Time     // Time of the previous throughput sample
+}
+
+// ManagerSummary aggregates the statistics of all sessions of an UploadManager,
+// or of the sessions of a SessionGroup.
+type ManagerSummary struct {
+	Sessions   int                  // Number of sessions
+	ByStatus   map[UploadStatus]int // Session count per status
+	Active     int                  // Sessions with a running upload goroutine
+	BytesSent  int64                // Bytes sent, excluding failed and cancelled sessions
+	BytesTotal int64                // Total bytes, excluding failed and cancelled sessions
+	Percentage float64              // Overall progress by bytes (0.0 to 100.0)
+	Speed      float64              // Combined current throughput of running sessions
+	ETA        time.Duration        // Estimated time for running and queued sessions (0 when unknown)
+}
+
+// initStats fills in the totals known before an upload starts.
+func (st *SessionStats) initStats(localPath string) {
+	if info, err := os.Stat(localPath); err == nil {
+		st.BytesTotal = info.Size()
+	}
+}
+
+// begin resets the per-attempt statistics for a new upload attempt, starting
+// from cp if the upload resumes from a checkpoint.
+func (st *SessionStats) begin(cp *Checkpoint, now time.Time) {
+	st.Attempts++
+	st.StartedAt = now
+	st.UpdatedAt = now
+	st.BytesSent, st.ChunksDone = 0, 0
+	if cp != nil {
+		st.BytesSent, st.ChunksDone, st.ChunksTotal = cp.BytesUploaded, cp.ChunksUploaded, cp.TotalChunks
+	}
+	st.percent()
+	st.attemptBase = st.BytesSent
+	st.activeTime = 0
+	st.AverageSpeed = 0
+}
+
+// running marks the start of a running period.
+func (st *SessionStats) running(now time.Time) {
+	st.runningSince = now
+	st.lastSample = now
+}
+
+// stopped marks the end of a running period.
+func (st *SessionStats) stopped(now time.Time) {
+	if !st.runningSince.IsZero() {
+		st.activeTime += now.Sub(st.runningSince)
+		st.runningSince = time.Time{}
+	}
+	st.CurrentSpeed = 0
+	st.ETA = 0
+}
+
+// progress records a progress report of the running upload.
+func (st *SessionStats) progress(info ProgressInfo, now time.Time) {
+	if dt := now.Sub(st.lastSample).Seconds(); !st.lastSample.IsZero() && dt > 0 && info.Current > st.BytesSent {
+		speed := float64(info.Current-st.BytesSent) / dt
+		if st.CurrentSpeed == 0 {
+			st.CurrentSpeed = speed
+		} else {
+			st.CurrentSpeed = speedSmoothing*speed + (1-speedSmoothing)*st.CurrentSpeed
+		}
+	}
+	st.lastSample = now
+	st.UpdatedAt = now
+
+	st.BytesSent = info.Current
+	st.BytesTotal = info.Total
+	st.ChunksDone = info.ChunkIndex + 1
+	st.ChunksTotal = info.TotalChunks
+	st.percent()
+
+	active := st.activeTime
+	if !st.runningSince.IsZero() {
+		active += now.Sub(st.runningSince)
+	}
+	if secs := active.Seconds(); secs > 0 {
+		st.AverageSpeed = float64(st.BytesSent-st.attemptBase) / secs
+	}
+	st.ETA = etaFor(st.BytesTotal-st.BytesSent, st.speed())
+}
+
+// completed records a finished upload.
+func (st *SessionStats) completed() {
+	st.BytesSent = st.BytesTotal
+	st.ChunksDone = st.ChunksTotal
+	st.Percentage = 100
+}
+
+// speed returns the throughput used for estimates.
+func (st *SessionStats) speed() float64 {
+	if st.CurrentSpeed > 0 {
+		return st.CurrentSpeed
+	}
+	return st.AverageSpeed
+}
+
+func (st *SessionStats) percent() {
+	if st.BytesTotal > 0 {
+		st.Percentage = float64(st.BytesSent) / float64(st.BytesTotal) * 100.0
+	}
+}
+
+// etaFor estimates the time to send remaining bytes at speed bytes per second.
+func etaFor(remaining int64, speed float64) time.Duration {
+	if remaining <= 0 || speed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(remaining) / speed * float64(time.Second))
+}
+
+// Summary returns statistics aggregated over all sessions of the manager.
+//
+// Example:
+//
+//	s := manager.Summary()
+//	fmt.Printf("%d/%d running, %.1f%% at %.1f MB/s, ETA %s\n",
+//		s.ByStatus[godav.StatusRunning], s.Sessions, s.Percentage, s.Speed/1e6, s.ETA)
+func (um *UploadManager) Summary() ManagerSummary {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	sessions := make([]*UploadSession, 0, len(um.sessions))
+	for _, sess := range um.sessions {
+		sessions = append(sessions, sess)
+	}
+	return summarize(sessions)
+}
+
+// summarize aggregates the statistics of sessions. um.mu must be held.
+func summarize(sessions []*UploadSession) ManagerSummary {
+	sum := ManagerSummary{
+		Sessions: len(sessions),
+		ByStatus: make(map[UploadStatus]int),
+	}
+	var pending int64
+	for _, sess := range sessions {
+		st := &sess.Stats
+		sum.ByStatus[sess.Status]++
+		if sess.running {
+			sum.Active++
+		}
+		if sess.Status == StatusFailed || sess.Status == StatusCancelled {
+			continue
+		}
+		sum.BytesSent += st.BytesSent
+		sum.BytesTotal += st.BytesTotal
+		if sess.Status == StatusRunning {
+			sum.Speed += st.speed()
+		}
+		if sess.Status != StatusCompleted {
+			pending += st.BytesTotal - st.BytesSent
+		}
+	}
+	if sum.BytesTotal > 0 {
+		sum.Percentage = float64(sum.BytesSent) / float64(sum.BytesTotal) * 100.0
+	}
+	sum.ETA = etaFor(pending, sum.Speed)
+	return sum
+}
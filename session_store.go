@@ -0,0 +1,174 @@
+// Package godav - Upload session persistence
+//
+// This file lets an UploadManager survive process restarts. With
+// ManagerConfig.Store set, the manager saves every session (paths, status,
+// priority, the serializable part of its config and its latest checkpoint)
+// whenever it changes. RestoreSessions reloads them after a restart and
+// resumes interrupted uploads from their checkpoints.
+//
+// Features:
+//   - Pluggable SessionStore interface
+//   - Built-in store writing one JSON file per session, atomically
+//   - Client re-attachment through a ClientResolver callback
+package godav
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionStore persists upload sessions for an UploadManager.
+// Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// Save creates or replaces the record with the same ID.
+	Save(rec SessionRecord) error
+	// Delete removes the record with the given ID. Deleting a missing record is not an error.
+	Delete(id string) error
+	// Load returns all stored records.
+	Load() ([]SessionRecord, error)
+}
+
+// SessionRecord is the persisted form of an UploadSession. Clients and
+// callbacks cannot be serialized; RestoreSessions re-attaches a client
+// through a ClientResolver, identified by BaseURL and Username.
+type SessionRecord struct {
+	ID             string        `json:"id"`                        // Session ID
+	LocalPath      string        `json:"local_path"`                // Local file path
+	RemotePath     string        `json:"remote_path"`               // Remote destination path
+	BaseURL        string        `json:"base_url"`                  // WebDAV base URL of the session's client
+	Username       string        `json:"username"`                  // Username of the session's client
+	Status         UploadStatus  `json:"status"`                    // Status when saved
+	Priority       int           `json:"priority"`                  // Queue priority
+	GroupID        string        `json:"group_id,omitempty"`        // Session group, if any
+	Owner          string        `json:"owner,omitempty"`           // Session owner, if any
+	DependsOn      []string      `json:"depends_on,omitempty"`      // Sessions that must complete first
+	FinalPath      string        `json:"final_path,omitempty"`      // Remote path after completion hooks
+	StartRequested bool          `json:"start_requested,omitempty"` // StartUpload was called while queued
+	Config         SessionConfig `json:"config"`                    // Serializable config fields
+	Checkpoint     *Checkpoint   `json:"checkpoint,omitempty"`      // Latest checkpoint, if any
+	Error          string        `json:"error,omitempty"`           // Failure cause of a failed session
+	Attempts       int           `json:"attempts,omitempty"`        // Upload attempts so far
+	Failures       int           `json:"failures,omitempty"`        // Failed upload attempts so far
+	CreatedAt      time.Time     `json:"created_at"`                // Session creation time
+	UpdatedAt      time.Time     `json:"updated_at"`                // Last update time
+}
+
+// SessionConfig holds the serializable fields of a session's Config.
+type SessionConfig struct {
+	ChunkSize    int64       `json:"chunk_size"`
+	SkipExisting bool        `json:"skip_existing"`
+	CompareMode  CompareMode `json:"compare_mode"`
+	MaxRetries   int         `json:"max_retries"`
+	Verbose      bool        `json:"verbose,omitempty"`
+}
+
+// ClientResolver returns the client to use for a restored session, typically
+// by looking up rec.BaseURL and rec.Username among the application's clients.
+type ClientResolver func(rec SessionRecord) (*Client, error)
+
+// FileSessionStore is a SessionStore keeping one JSON file per session in a
+// directory. Files are written to a temporary file and renamed into place, so
+// a crash never leaves a torn record.
+type FileSessionStore struct {
+	dir string
+}
+
+// NewFileSessionStore creates a store in dir, creating the directory if needed.
+//
+// Example:
+//
+//	store, err := godav.NewFileSessionStore("/var/lib/myapp/sessions")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cfg := godav.DefaultManagerConfig()
+//	cfg.Store = store
+//	manager := godav.NewUploadManagerWithConfig(cfg)
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create session store: %w", err)
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Save writes the record atomically.
+func (s *FileSessionStore) Save(rec SessionRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal session %s: %w", rec.ID, err)
+	}
+	if err := writeFileAtomic(s.path(rec.ID), data, 0o600); err != nil {
+		return fmt.Errorf("save session %s: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// Delete removes the record file.
+func (s *FileSessionStore) Delete(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete session %s: %w", id, err)
+	}
+	return nil
+}
+
+// Load reads every record in the directory, oldest first.
+func (s *FileSessionStore) Load() ([]SessionRecord, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]SessionRecord, 0, len(matches))
+	for _, p := range matches {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("load session: %w", err)
+		}
+		var rec SessionRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("load session %s: %w", filepath.Base(p), err)
+		}
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records, nil
+}
+
+func (s *FileSessionStore) path(id string) string {
+	// Session IDs embed a file name; keep them to a single path element
+	id = strings.NewReplacer("/", "_", "\\", "_").Replace(id)
+	return filepath.Join(s.dir, id+".json")
+}
+
+// errorString returns err's message, or "" for a nil error.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// sessionConfigOf extracts the serializable fields of cfg.
+func sessionConfigOf(cfg *Config) SessionConfig {
+	return SessionConfig{
+		ChunkSize:    cfg.ChunkSize,
+		SkipExisting: cfg.SkipExisting,
+		CompareMode:  cfg.CompareMode,
+		MaxRetries:   cfg.MaxRetries,
+		Verbose:      cfg.Verbose,
+	}
+}
+
+// apply copies the serializable fields onto cfg.
+func (sc SessionConfig) apply(cfg *Config) {
+	cfg.ChunkSize = sc.ChunkSize
+	cfg.SkipExisting = sc.SkipExisting
+	cfg.CompareMode = sc.CompareMode
+	cfg.MaxRetries = sc.MaxRetries
+	cfg.Verbose = sc.Verbose
+}
@@ -4,6 +4,8 @@
 // including progress tracking, event handling, error types, and configuration options.
 package godav
 
+import "time"
+
 // ProgressInfo contains detailed progress information for uploads.
 type ProgressInfo struct {
 	Filename    string  // Name of the file being uploaded
@@ -15,6 +17,52 @@ type ProgressInfo struct {
 	SessionID   string  // Upload session ID for multi-client support
 }
 
+// DirProgressInfo contains aggregate progress information for directory uploads.
+type DirProgressInfo struct {
+	LocalDir       string        // Local directory being uploaded
+	FilesTotal     int           // Total number of files found by the pre-scan
+	FilesDone      int           // Files finished so far (uploaded, skipped or failed)
+	FilesSkipped   int           // Files skipped because they already exist remotely
+	FilesFailed    int           // Files that failed to upload
+	BytesTotal     int64         // Total size of all files in bytes
+	BytesDone      int64         // Bytes accounted for so far, including in-flight files
+	Percentage     float64       // Overall progress by bytes (0.0 to 100.0)
+	CurrentFiles   []string      // Relative paths of files currently uploading
+	BytesPerSecond float64       // Average throughput of bytes actually transferred
+	Elapsed        time.Duration // Time since the upload started (excluding the pre-scan)
+	ETA            time.Duration // Estimated time remaining (0 when unknown)
+}
+
+// DirEntryResult describes the outcome of a single entry of a directory upload.
+type DirEntryResult struct {
+	RelPath    string        // Slash-separated path relative to the uploaded directory
+	LocalPath  string        // Local path of the entry
+	RemotePath string        // Remote destination path
+	IsDir      bool          // Whether the entry is a directory
+	LinkTarget string        // Symlink target (SymlinkAsFile entries only)
+	Size       int64         // File size in bytes
+	Duration   time.Duration // Time spent on this entry
+	Reason     string        // Why the entry was skipped (skipped entries only)
+	Err        error         // Failure cause (failed entries only)
+}
+
+// DirUploadResult is the structured report returned by UploadDir.
+type DirUploadResult struct {
+	LocalDir      string           // Local directory that was uploaded
+	RemoteDir     string           // Remote destination directory
+	Uploaded      []DirEntryResult // Files uploaded successfully
+	Skipped       []DirEntryResult // Files skipped (e.g. already present remotely)
+	Failed        []DirEntryResult // Files and directories that failed
+	BytesUploaded int64            // Total size of uploaded files
+	BytesSkipped  int64            // Total size of skipped files
+	BytesFailed   int64            // Total size of failed files
+	AlreadyDone   int              // Entries completed by a previous run (ResumeDir only)
+	StartedAt     time.Time        // When the upload started
+	Duration      time.Duration    // Total duration including the pre-scan
+
+	archive bool // Set by UploadArchive, whose result has no LocalDir
+}
+
 // UploadEvent represents different stages of the upload process
 type UploadEvent string
 
@@ -31,13 +79,50 @@ const (
 	EventUploadResumed  UploadEvent = "upload_resumed"  // Upload resumed from checkpoint
 )
 
+// Directory upload events, emitted by UploadDir through EventFunc
+const (
+	EventDirScanStarted UploadEvent = "dir_scan_started" // Directory pre-scan started
+	EventDirScanDone    UploadEvent = "dir_scan_done"    // Directory pre-scan finished
+	EventDirComplete    UploadEvent = "dir_complete"     // Directory or archive upload finished
+)
+
 // UploadState represents the current state of an upload
 type UploadState int
 
 const (
-	StateRunning UploadState = iota
-	StatePaused
-	StateCancelled
+	StateRunning   UploadState = iota // Uploading
+	StatePaused                       // Paused, waiting for Resume
+	StateCancelled                    // Cancelled (terminal)
+	StateCompleted                    // Finished successfully (terminal)
+	StateFailed                       // Finished with an error (terminal)
+)
+
+// SymlinkPolicy controls how directory uploads treat symbolic links
+type SymlinkPolicy int
+
+const (
+	SymlinkSkip   SymlinkPolicy = iota // Ignore symbolic links
+	SymlinkFollow                      // Upload link targets and descend into linked directories (cycle-safe)
+	SymlinkAsFile                      // Upload a small "<name>.symlink" file describing the link target
+)
+
+// CompareMode selects how SkipExisting decides that a remote file is unchanged
+type CompareMode int
+
+const (
+	CompareSize      CompareMode = iota // Same size (fast, but misses same-length edits)
+	CompareSizeMtime                    // Same size and modification time (to the second)
+	CompareChecksum                     // Same size and content hash, via the server's oc:checksums property
+	CompareAlways                       // Never skip; always upload
+)
+
+// ArchiveFormat selects the container format read by UploadArchive
+type ArchiveFormat int
+
+const (
+	ArchiveTar   ArchiveFormat = iota // Uncompressed tar
+	ArchiveTarGz                      // Gzip-compressed tar (.tar.gz, .tgz)
+	ArchiveZip                        // Zip (spooled to a temporary file unless the reader supports random access)
 )
 
 // UploadStatus represents the status of an upload session
@@ -81,8 +166,22 @@ type Config struct {
 
 	// SkipExisting when true, skips files that already exist with the same size.
 	// This provides efficient synchronization by avoiding unnecessary uploads.
+	// CompareMode selects a stricter comparison.
 	SkipExisting bool
 
+	// CompareMode controls how SkipExisting compares local and remote files.
+	// The default, CompareSize, only compares sizes. CompareSizeMtime also
+	// compares modification times (uploads set the remote mtime to the local
+	// one). CompareChecksum compares content hashes with the server's
+	// oc:checksums property; uploads in this mode send OC-Checksum so the
+	// server records one. CompareAlways disables skipping.
+	CompareMode CompareMode
+
+	// HashCache stores hashes of local files for CompareChecksum, so files
+	// that did not change are not rehashed. Defaults to an in-memory cache
+	// shared by all uploads of the client.
+	HashCache HashCache
+
 	// Verbose enables detailed logging of upload operations, including
 	// chunk progress, directory creation, and retry attempts.
 	Verbose bool
@@ -97,6 +196,33 @@ type Config struct {
 	// Use this for implementing custom upload monitoring and logging.
 	EventFunc func(info EventInfo)
 
+	// DirProgressFunc is called during UploadDir to report aggregate progress
+	// across the whole directory: file and byte counts, the files currently
+	// uploading, throughput and an ETA. Called after every chunk and file.
+	DirProgressFunc func(info DirProgressInfo)
+
+	// DirFailFast when true, stops UploadDir at the first file or directory
+	// that fails instead of continuing with the remaining entries.
+	DirFailFast bool
+
+	// SymlinkPolicy controls how UploadDir treats symbolic links. SymlinkFollow
+	// uploads link targets and descends into linked directories, skipping any
+	// link that would create a cycle. Links inside archives cannot be followed,
+	// so UploadArchive skips them unless SymlinkAsFile is set.
+	// Default: SymlinkSkip
+	SymlinkPolicy SymlinkPolicy
+
+	// SkipHidden when true, makes UploadDir and UploadArchive skip files and
+	// directories whose name starts with a dot. Skipped entries are listed in the result.
+	// Device files, sockets and named pipes are always skipped.
+	SkipHidden bool
+
+	// DirCheckpointPath when set, makes UploadDir journal its progress to this
+	// file: completed entries and the latest checkpoint of the file in flight.
+	// Pass the same path to ResumeDir to continue an interrupted upload.
+	// The journal is removed when the directory upload finishes without failures.
+	DirCheckpointPath string
+
 	// MaxRetries specifies the maximum number of retry attempts for failed chunks.
 	// Each chunk will be retried up to this many times before giving up.
 	// Range: 0-10 (default 3)
@@ -112,13 +238,37 @@ type Config struct {
 	// Use NewUploadController() or NewSimpleUploadController() to create.
 	Controller *UploadController
 
+	// PauseTimeout limits how long an upload paused through its Controller
+	// waits for Resume before failing with ErrPauseTimeout.
+	// Default: 0 (wait indefinitely)
+	PauseTimeout time.Duration
+
 	// CheckpointFunc is called periodically to save upload progress.
 	// The callback receives a Checkpoint struct that can be persisted
 	// and used later to resume interrupted uploads.
 	CheckpointFunc func(cp Checkpoint)
 
+	// CheckpointEveryChunks, CheckpointEveryBytes and CheckpointInterval set
+	// how often a checkpoint is saved while uploading: after that many
+	// chunks, bytes or that much time since the last checkpoint, whichever
+	// comes first. Zero disables a trigger. Checkpoints are also saved on
+	// pause and when a chunk fails permanently.
+	// Default: every 10 chunks when all three are zero
+	CheckpointEveryChunks int
+	CheckpointEveryBytes  int64
+	CheckpointInterval    time.Duration
+
 	// ResumeFromCheckpoint when specified, resumes an upload from the
 	// given checkpoint instead of starting a new upload.
 	// Load checkpoints using LoadCheckpoint().
 	ResumeFromCheckpoint *Checkpoint
+
+	// CheckpointStore when set, receives the checkpoints of file uploads
+	// alongside CheckpointFunc. Uploads without ResumeFromCheckpoint resume
+	// from a stored checkpoint of the same local and remote path, and the
+	// checkpoint is deleted once the upload completes or is cancelled.
+	// Use NewFileCheckpointStore() or NewMemoryCheckpointStore() to create.
+	CheckpointStore CheckpointStore
+
+	throttle *rateLimiter // Byte-rate limit shared with other uploads (set by UploadManager)
 }
@@ -3,29 +3,48 @@
 // This file provides pause/resume/cancel functionality for individual uploads
 // and global control across multiple upload sessions. It includes thread-safe
 // state management and coordination between upload sessions.
+//
+// Features:
+//   - Pause, resume and cancel that abort the chunk request in flight
+//   - Subscriptions to state transitions and waiting for a state
+//   - Completed and failed terminal states with the failure cause
 package godav
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
-// GlobalController provides global pause/resume control across all uploads
+// GlobalController provides global and scoped pause/resume control across
+// all uploads of an UploadManager (see manager_pause.go)
 type GlobalController struct {
 	globalPaused bool
-	pauseCh      chan struct{}
-	resumeCh     chan struct{}
+	gate         pauseGate // Client, owner and group pauses
 	mu           sync.RWMutex
 }
 
+// stateChangeBuffer is the channel capacity of a state subscription.
+const stateChangeBuffer = 16
+
+// StateChange describes a transition of an UploadController's state.
+type StateChange struct {
+	From UploadState // Previous state
+	To   UploadState // New state
+	Err  error       // Cause of StateFailed or StateCancelled
+	Time time.Time   // Time of the transition
+}
+
 // UploadController provides pause/resume functionality for individual uploads
 type UploadController struct {
 	sessionID string
 	state     UploadState
-	stateCh   chan UploadState
-	pauseCh   chan struct{}
-	resumeCh  chan struct{}
+	err       error                     // Cause of a failed or cancelled upload
+	changed   chan struct{}             // Closed and replaced on every state change
+	subs      map[chan StateChange]bool // State subscriptions
+	stopping  bool                      // Stop at the next chunk boundary (see stopAtBoundary)
 	manager   *UploadManager
 	mu        sync.RWMutex
 }
@@ -35,9 +54,8 @@ func NewUploadController(sessionID string, manager *UploadManager) *UploadContro
 	return &UploadController{
 		sessionID: sessionID,
 		state:     StateRunning,
-		stateCh:   make(chan UploadState, 1),
-		pauseCh:   make(chan struct{}, 1),
-		resumeCh:  make(chan struct{}, 1),
+		changed:   make(chan struct{}),
+		subs:      make(map[chan StateChange]bool),
 		manager:   manager,
 	}
 }
@@ -47,22 +65,18 @@ func NewSimpleUploadController() *UploadController {
 	return &UploadController{
 		sessionID: fmt.Sprintf("session-%d", time.Now().UnixNano()),
 		state:     StateRunning,
-		stateCh:   make(chan UploadState, 1),
-		pauseCh:   make(chan struct{}, 1),
-		resumeCh:  make(chan struct{}, 1),
+		changed:   make(chan struct{}),
+		subs:      make(map[chan StateChange]bool),
 	}
 }
 
-// Pause pauses the upload
+// Pause pauses the upload. A chunk request in flight is aborted and sent
+// again after Resume.
 func (uc *UploadController) Pause() {
 	uc.mu.Lock()
 	defer uc.mu.Unlock()
 	if uc.state == StateRunning {
-		uc.state = StatePaused
-		select {
-		case uc.pauseCh <- struct{}{}:
-		default:
-		}
+		uc.setStateLocked(StatePaused, nil)
 	}
 }
 
@@ -71,19 +85,18 @@ func (uc *UploadController) Resume() {
 	uc.mu.Lock()
 	defer uc.mu.Unlock()
 	if uc.state == StatePaused {
-		uc.state = StateRunning
-		select {
-		case uc.resumeCh <- struct{}{}:
-		default:
-		}
+		uc.setStateLocked(StateRunning, nil)
 	}
 }
 
-// Cancel cancels the upload
+// Cancel cancels the upload. A chunk request in flight is aborted and a
+// paused upload stops waiting for Resume. Finished uploads are unaffected.
 func (uc *UploadController) Cancel() {
 	uc.mu.Lock()
-	uc.state = StateCancelled
-	uc.mu.Unlock()
+	defer uc.mu.Unlock()
+	if !uc.state.terminal() {
+		uc.setStateLocked(StateCancelled, ErrUploadCancelled)
+	}
 }
 
 // State returns the current upload state
@@ -92,3 +105,218 @@ func (uc *UploadController) State() UploadState {
 	defer uc.mu.RUnlock()
 	return uc.state
 }
+
+// Err returns the cause of a failed or cancelled upload, or nil.
+func (uc *UploadController) Err() error {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.err
+}
+
+// Subscribe returns a channel of the controller's state transitions and a
+// function that unsubscribes and closes the channel. The channel is closed
+// after the transition to a terminal state (StateCompleted, StateFailed or
+// StateCancelled); for a finished upload it is returned closed. If the
+// subscriber falls behind, the oldest transitions are dropped.
+//
+// Example:
+//
+//	changes, unsubscribe := controller.Subscribe()
+//	defer unsubscribe()
+//	for change := range changes {
+//		fmt.Printf("%s -> %s\n", change.From, change.To)
+//	}
+func (uc *UploadController) Subscribe() (<-chan StateChange, func()) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	ch := make(chan StateChange, stateChangeBuffer)
+	if uc.state.terminal() {
+		close(ch)
+		return ch, func() {}
+	}
+	uc.subs[ch] = true
+	return ch, func() {
+		uc.mu.Lock()
+		defer uc.mu.Unlock()
+		if uc.subs[ch] {
+			delete(uc.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// WaitForState blocks until the upload is in state, ctx is done or the
+// upload finished in another state, in which case the error wraps its
+// failure cause, if any. A state left again before WaitForState observed it
+// may be missed; use Subscribe to see every transition.
+//
+// Example:
+//
+//	if err := controller.WaitForState(ctx, godav.StateCompleted); err != nil {
+//		log.Printf("upload did not complete: %v", err)
+//	}
+func (uc *UploadController) WaitForState(ctx context.Context, state UploadState) error {
+	for {
+		uc.mu.RLock()
+		cur, changed, cause := uc.state, uc.changed, uc.err
+		uc.mu.RUnlock()
+		switch {
+		case cur == state:
+			return nil
+		case cur.terminal() && cause != nil:
+			return fmt.Errorf("wait for %s: upload %s: %w", state, cur, cause)
+		case cur.terminal():
+			return fmt.Errorf("wait for %s: upload %s", state, cur)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// finish records the outcome of an upload run by its owner (UploadManager
+// or UploadFileResumable): StateCompleted if err is nil, StateFailed
+// otherwise. A cancelled or already finished upload keeps its state.
+func (uc *UploadController) finish(err error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	switch {
+	case uc.state.terminal():
+	case err == nil:
+		uc.setStateLocked(StateCompleted, nil)
+	case errors.Is(err, ErrUploadCancelled):
+		uc.setStateLocked(StateCancelled, err)
+	default:
+		uc.setStateLocked(StateFailed, err)
+	}
+}
+
+// setStateLocked changes the state, wakes the waiters and notifies the
+// subscribers, closing their channels once the upload finished.
+// uc.mu must be held.
+func (uc *UploadController) setStateLocked(state UploadState, err error) {
+	change := StateChange{From: uc.state, To: state, Err: err, Time: time.Now()}
+	uc.state = state
+	uc.err = err
+	close(uc.changed)
+	uc.changed = make(chan struct{})
+
+	for ch := range uc.subs {
+		for sent := false; !sent; {
+			select {
+			case ch <- change:
+				sent = true
+			default:
+				// Full: drop the oldest transition
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+		if state.terminal() {
+			delete(uc.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// watch returns the current state and a channel closed on its next change.
+func (uc *UploadController) watch() (UploadState, <-chan struct{}) {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.state, uc.changed
+}
+
+// stopAtBoundary asks the upload to save a checkpoint once the chunk in
+// flight is confirmed, without aborting it. The owner of the checkpoint
+// callback stops the upload by cancelling its context.
+func (uc *UploadController) stopAtBoundary() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.stopping = true
+}
+
+// stopRequested reports whether stopAtBoundary was called. A nil controller
+// never stops.
+func (uc *UploadController) stopRequested() bool {
+	if uc == nil {
+		return false
+	}
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.stopping
+}
+
+// runningContext returns a context derived from parent that is cancelled
+// once the upload is no longer running, to abort the request in flight.
+// A nil controller never cancels it.
+func (uc *UploadController) runningContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if uc == nil {
+		return ctx, cancel
+	}
+	go func() {
+		for {
+			state, changed := uc.watch()
+			if state != StateRunning {
+				cancel()
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ctx, cancel
+}
+
+// waitWhilePaused blocks while the upload is paused. It fails with
+// ErrPauseTimeout once the pause outlasts timeout; 0 waits indefinitely.
+func (uc *UploadController) waitWhilePaused(ctx context.Context, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		state, changed := uc.watch()
+		if state != StatePaused {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-expired:
+			return ErrPauseTimeout
+		}
+	}
+}
+
+// String returns the name of the state.
+func (s UploadState) String() string {
+	switch s {
+	case StateRunning:
+		return "running"
+	case StatePaused:
+		return "paused"
+	case StateCancelled:
+		return "cancelled"
+	case StateCompleted:
+		return "completed"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("UploadState(%d)", int(s))
+}
+
+// terminal reports whether the upload has finished.
+func (s UploadState) terminal() bool {
+	return s == StateCancelled || s == StateCompleted || s == StateFailed
+}
@@ -0,0 +1,155 @@
+// Package godav - Background upload handles
+//
+// This file starts single-file uploads in the background. Each call to
+// UploadFileResumable returns its own UploadHandle, with its own controller
+// and progress, through which the caller controls the upload and learns when
+// and how it finished.
+//
+// Features:
+//   - Wait for the result or select on Done
+//   - Latest progress of the upload
+//   - Pause, resume and cancel per handle
+package godav
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UploadResult describes an upload finished through an UploadHandle.
+type UploadResult struct {
+	LocalPath  string        // Uploaded local file
+	RemotePath string        // Remote destination path
+	Size       int64         // File size in bytes
+	Skipped    bool          // The upload was skipped because the remote file was unchanged
+	StartedAt  time.Time     // When the upload started
+	Duration   time.Duration // Time spent on the upload
+}
+
+// UploadHandle controls an upload started by UploadFileResumable.
+type UploadHandle struct {
+	controller *UploadController
+	done       chan struct{} // Closed once the upload finished
+	result     *UploadResult // Set before done is closed
+	err        error         // Set before done is closed
+	progress   ProgressInfo  // Latest progress
+	mu         sync.RWMutex
+}
+
+// UploadFileResumable starts uploading a file in the background and returns
+// a handle to control it and collect its result. Every call gets its own
+// controller, so handles of uploads on the same client are independent; the
+// Controller of the client's config is not used.
+//
+// Example:
+//
+//	handle, err := client.UploadFileResumable("/data/backup.tar", "Backups/backup.tar")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	handle.Pause()
+//	handle.Resume()
+//	result, err := handle.Wait()
+//	if err == nil {
+//		fmt.Printf("uploaded %d bytes in %s\n", result.Size, result.Duration)
+//	}
+func (c *Client) UploadFileResumable(localPath, dstPath string) (*UploadHandle, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", localPath, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("upload %s: is a directory", localPath)
+	}
+
+	cfg := DefaultConfig()
+	if c.config != nil {
+		own := *c.config
+		cfg = &own
+	}
+	h := &UploadHandle{
+		controller: NewSimpleUploadController(),
+		done:       make(chan struct{}),
+		progress:   ProgressInfo{Filename: filepath.Base(localPath), Total: info.Size()},
+	}
+	cfg.Controller = h.controller
+	userProgress := cfg.ProgressFunc
+	cfg.ProgressFunc = func(p ProgressInfo) {
+		h.mu.Lock()
+		h.progress = p
+		h.mu.Unlock()
+		if userProgress != nil {
+			userProgress(p)
+		}
+	}
+	client := c.withConfig(cfg)
+
+	go func() {
+		started := time.Now()
+		skipped, err := client.uploadFileCore(context.Background(), localPath, dstPath)
+
+		h.mu.Lock()
+		h.err = err
+		if err == nil {
+			h.result = &UploadResult{
+				LocalPath:  localPath,
+				RemotePath: client.sanitizeRemotePath(dstPath),
+				Size:       info.Size(),
+				Skipped:    skipped,
+				StartedAt:  started,
+				Duration:   time.Since(started),
+			}
+		}
+		h.mu.Unlock()
+		h.controller.finish(err)
+		close(h.done)
+	}()
+
+	return h, nil
+}
+
+// Wait blocks until the upload finished and returns its result, or the
+// error that ended it (ErrUploadCancelled after Cancel).
+func (h *UploadHandle) Wait() (*UploadResult, error) {
+	<-h.done
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.result, h.err
+}
+
+// Done returns a channel that is closed once the upload finished.
+func (h *UploadHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Progress returns the latest progress of the upload.
+func (h *UploadHandle) Progress() ProgressInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.progress
+}
+
+// Pause pauses the upload (see UploadController.Pause).
+func (h *UploadHandle) Pause() {
+	h.controller.Pause()
+}
+
+// Resume resumes a paused upload.
+func (h *UploadHandle) Resume() {
+	h.controller.Resume()
+}
+
+// Cancel cancels the upload and removes its uploaded chunks from the server.
+func (h *UploadHandle) Cancel() {
+	h.controller.Cancel()
+}
+
+// Controller returns the upload's controller, e.g. to subscribe to its state
+// transitions.
+func (h *UploadHandle) Controller() *UploadController {
+	return h.controller
+}
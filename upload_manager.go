@@ -7,23 +7,131 @@
 // Features:
 //   - Multi-client upload coordination
 //   - Session lifecycle management (queued, running, paused, completed, failed, cancelled)
-//   - Global pause/resume across all uploads
+//   - Cancellation with server-side cleanup, failure causes and Wait/Done
+//   - Live session statistics and a manager-wide summary
+//   - Automatic retries of failed sessions (see session_retry.go)
+//   - Session groups for directory and batch uploads (see session_group.go)
+//   - Not-before times and recurring upload windows (see session_schedule.go)
+//   - Per-owner fairness, rate limits and daily quotas (see session_owner.go)
+//   - Retention of finished sessions and bulk removal (see session_retention.go)
+//   - Clean shutdown with checkpoints (see manager_shutdown.go)
+//   - Session dependencies and completion hooks (see session_deps.go)
+//   - Concurrency limit with a priority/FIFO queue scheduler
+//   - Optional persistence and restore through a SessionStore
+//   - Event subscriptions (see manager_events.go)
+//   - Global and scoped pause/resume (see manager_pause.go)
 //   - Thread-safe session state management
 //   - Session cleanup and resource management
 package godav
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/studio-b12/gowebdav"
 )
 
 // UploadManager manages multiple concurrent uploads across different clients
 type UploadManager struct {
-	sessions   map[string]*UploadSession
-	globalCtrl *GlobalController
-	mu         sync.RWMutex
+	sessions      map[string]*UploadSession
+	groups        map[string]*SessionGroup
+	owners        map[string]*ownerState
+	globalCtrl    *GlobalController
+	scheduleTimer *time.Timer // Wakes the scheduler when a session schedule changes
+	janitorTimer  *time.Timer // Runs the retention janitor
+	config        ManagerConfig
+	events        eventBus
+	seq           int64 // Queue sequence counter, for FIFO order within a priority
+	active        int   // Sessions with a running upload goroutine
+	serveSeq      int64 // Counter of slots handed out, for round-robin fairness
+	closed        bool  // Shutdown was called: no more sessions are started
+	uploads       sync.WaitGroup
+	mu            sync.RWMutex
+}
+
+// ManagerConfig holds options for an UploadManager.
+//
+// Use DefaultManagerConfig() to get sensible defaults, then customize as needed:
+//
+//	cfg := godav.DefaultManagerConfig()
+//	cfg.MaxConcurrent = 4
+//	cfg.AutoStart = true
+//	manager := godav.NewUploadManagerWithConfig(cfg)
+type ManagerConfig struct {
+	// MaxConcurrent limits how many sessions upload at the same time.
+	// Sessions started beyond the limit stay queued and are started by the
+	// scheduler as slots free up. Paused sessions keep their slot.
+	// 0 means unlimited (default).
+	MaxConcurrent int
+
+	// AutoStart when true, makes the scheduler start queued sessions on its
+	// own as slots free up, without waiting for StartUpload.
+	AutoStart bool
+
+	// Store when set, persists every session and its latest checkpoint so
+	// that RestoreSessions can reload them after a restart.
+	// Use NewFileSessionStore() for the built-in JSON file store.
+	Store SessionStore
+
+	// Retry controls automatic retries of failed sessions.
+	// Default: no retries.
+	Retry RetryPolicy
+
+	// Schedule when set, restricts when sessions may upload, unless a
+	// session has its own (see SessionOptions.Schedule).
+	// Default: nil (any time).
+	Schedule *UploadSchedule
+
+	// Fairness selects how free slots are shared between session owners
+	// (see SessionOptions.Owner).
+	// Default: FairnessNone (global priority order).
+	Fairness Fairness
+
+	// OwnerLimits applies to every owner without limits of its own
+	// (see SetOwnerLimits), including sessions without an owner.
+	// Default: no limits.
+	OwnerLimits OwnerLimits
+
+	// Retention removes finished sessions by age and count, so that
+	// long-running managers do not keep them forever.
+	// Default: finished sessions are kept until removed.
+	Retention RetentionPolicy
+}
+
+// SessionOptions holds per-session options for AddUploadSessionWithOptions.
+type SessionOptions struct {
+	// Priority orders the queue: higher priorities start first, sessions of
+	// equal priority start in the order they were queued. Default 0.
+	Priority int
+
+	// Retry overrides ManagerConfig.Retry for this session.
+	// Restored sessions use the manager's policy.
+	Retry *RetryPolicy
+
+	// Schedule overrides ManagerConfig.Schedule for this session.
+	// Restored sessions use the manager's schedule.
+	Schedule *UploadSchedule
+
+	// Owner is the user or tenant the session is uploaded for, used for
+	// per-owner limits and fair scheduling. Default "" (no owner).
+	Owner string
+
+	// DependsOn lists sessions that must complete before this session
+	// starts. If one of them fails or is cancelled, this session is
+	// cancelled with ErrDependencyFailed.
+	DependsOn []string
+
+	// Hooks run in order after the upload succeeded, e.g. MoveHook or
+	// ShareHook. Restored sessions have no hooks.
+	Hooks []CompletionHook
 }
 
 // UploadSession represents a single upload session
@@ -35,8 +143,74 @@ type UploadSession struct {
 	Controller *UploadController
 	Config     *Config
 	Status     UploadStatus
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	Priority   int             // Queue priority (higher starts first)
+	Checkpoint *Checkpoint     // Latest checkpoint of the upload (nil if none)
+	Err        error           // Why the session failed, or ErrUploadCancelled (nil otherwise)
+	Stats      SessionStats    // Live progress, throughput and attempt statistics
+	NextRetry  time.Time       // When a queued retry may start (zero if no retry is pending)
+	GroupID    string          // Group the session belongs to (empty if none)
+	Schedule   *UploadSchedule // When the session may upload (nil for any time)
+	Owner      string          // User or tenant the session belongs to (empty if none)
+	DependsOn  []string        // Sessions that must complete before this one starts
+	FinalPath  string          // Remote path of the completed upload, after completion hooks
+
+	PausedBySchedule bool // Paused because its time window closed; resumed when it reopens
+	PausedByQuota    bool // Paused because its owner's daily quota is used up; resumed the next day
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+
+	seq            int64              // Queue position within its priority
+	startRequested bool               // StartUpload was called while no slot was free
+	running        bool               // An upload goroutine is active for the session
+	detached       bool               // Paused or queued without an upload goroutine (restored, or stopped by its schedule or quota)
+	checkpointStop bool               // The upload stops once its checkpoint is saved (window closed, quota ran out or shutdown)
+	cancel         context.CancelFunc // Cancels the running upload's context
+	done           chan struct{}      // Closed once the session has finished
+	finished       bool               // done has been closed
+	retry          *RetryPolicy       // Per-session retry policy (nil uses the manager's)
+	retryTimer     *time.Timer        // Wakes the scheduler when NextRetry is reached
+	hooks          []CompletionHook   // Run after a successful upload
+}
+
+// Done returns a channel that is closed once the session has finished:
+// completed, failed or cancelled, with its upload goroutine exited. Copies
+// returned by GetUploadSession share the channel of the managed session.
+func (s *UploadSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// isTerminal reports whether a session in status can no longer change.
+func isTerminal(status UploadStatus) bool {
+	return status == StatusCompleted || status == StatusFailed || status == StatusCancelled
+}
+
+// finishLocked closes the session's done channel and finishes its controller
+// once it has reached a terminal status and its upload goroutine has exited.
+// um.mu must be held.
+func (sess *UploadSession) finishLocked() {
+	if sess.done != nil && !sess.finished && !sess.running && isTerminal(sess.Status) {
+		sess.finished = true
+		close(sess.done)
+		// Let observers of the controller learn how the session finished
+		switch sess.Status {
+		case StatusCompleted:
+			sess.Controller.finish(nil)
+		case StatusCancelled:
+			sess.Controller.Cancel()
+		default:
+			err := sess.Err
+			if err == nil {
+				err = errors.New("upload failed")
+			}
+			sess.Controller.finish(err)
+		}
+	}
+}
+
+// DefaultManagerConfig returns the defaults used by NewUploadManager:
+// unlimited concurrency and no automatic starts.
+func DefaultManagerConfig() ManagerConfig {
+	return ManagerConfig{}
 }
 
 // NewUploadManager creates a new upload manager for coordinating multiple uploads.
@@ -54,13 +228,42 @@ type UploadSession struct {
 //	}
 //	err = manager.StartUpload(session.ID)
 func NewUploadManager() *UploadManager {
-	return &UploadManager{
+	return NewUploadManagerWithConfig(DefaultManagerConfig())
+}
+
+// NewUploadManagerWithConfig creates an upload manager with the given options.
+//
+// Example:
+//
+//	cfg := godav.DefaultManagerConfig()
+//	cfg.MaxConcurrent = 4
+//	cfg.AutoStart = true
+//	manager := godav.NewUploadManagerWithConfig(cfg)
+//	for _, f := range files {
+//		_, _ = manager.AddUploadSession(f, "Backups/"+filepath.Base(f), client) // starts 4 at a time
+//	}
+func NewUploadManagerWithConfig(cfg ManagerConfig) *UploadManager {
+	if cfg.MaxConcurrent < 0 {
+		cfg.MaxConcurrent = 0
+	}
+	cfg.Schedule = cfg.Schedule.clone()
+	um := &UploadManager{
 		sessions: make(map[string]*UploadSession),
+		groups:   make(map[string]*SessionGroup),
+		owners:   make(map[string]*ownerState),
 		globalCtrl: &GlobalController{
-			pauseCh:  make(chan struct{}, 1),
-			resumeCh: make(chan struct{}, 1),
+			gate: pauseGate{
+				clients: make(map[*Client]bool),
+				owners:  make(map[string]bool),
+				groups:  make(map[string]bool),
+			},
 		},
+		config: cfg,
 	}
+	um.mu.Lock()
+	um.armJanitorLocked()
+	um.mu.Unlock()
+	return um
 }
 
 // AddUploadSession adds a new upload session to the manager.
@@ -83,10 +286,34 @@ func NewUploadManager() *UploadManager {
 //	}
 //	fmt.Printf("Created session: %s\n", session.ID)
 func (um *UploadManager) AddUploadSession(localPath, remotePath string, client *Client) (*UploadSession, error) {
+	return um.AddUploadSessionWithOptions(localPath, remotePath, client, SessionOptions{})
+}
+
+// AddUploadSessionWithOptions adds a new upload session with per-session options
+// such as its queue priority. See AddUploadSession.
+func (um *UploadManager) AddUploadSessionWithOptions(localPath, remotePath string, client *Client, opts SessionOptions) (*UploadSession, error) {
 	um.mu.Lock()
 	defer um.mu.Unlock()
 
+	if um.closed {
+		return nil, ErrManagerShutdown
+	}
+	if err := um.checkDependenciesLocked(opts.DependsOn); err != nil {
+		return nil, fmt.Errorf("add session: %w", err)
+	}
+	session := um.addSessionLocked(localPath, remotePath, client, opts, "")
+	um.scheduleLocked()
+	return session, nil
+}
+
+// addSessionLocked creates and saves a queued session without scheduling it.
+// um.mu must be held.
+func (um *UploadManager) addSessionLocked(localPath, remotePath string, client *Client, opts SessionOptions, groupID string) *UploadSession {
+	um.seq++
 	sessionID := fmt.Sprintf("upload-%d-%s", time.Now().UnixNano(), filepath.Base(localPath))
+	if _, exists := um.sessions[sessionID]; exists {
+		sessionID = fmt.Sprintf("%s-%d", sessionID, um.seq)
+	}
 
 	controller := NewUploadController(sessionID, um)
 	if client.config == nil {
@@ -104,47 +331,522 @@ func (um *UploadManager) AddUploadSession(localPath, remotePath string, client *
 		Controller: controller,
 		Config:     &sessCfg,
 		Status:     StatusQueued,
+		Priority:   opts.Priority,
+		GroupID:    groupID,
+		Schedule:   opts.Schedule.clone(),
+		Owner:      opts.Owner,
+		DependsOn:  slices.Clone(opts.DependsOn),
+		hooks:      slices.Clone(opts.Hooks),
+		retry:      opts.Retry,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
+		seq:        um.seq,
+		done:       make(chan struct{}),
+	}
+	if session.Schedule == nil {
+		session.Schedule = um.config.Schedule
 	}
+	session.Stats.initStats(localPath)
 
 	um.sessions[sessionID] = session
-	return session, nil
+	um.persistLocked(session)
+	return session
 }
 
-// StartUpload starts an upload session
+// StartUpload starts an upload session.
+//
+// If MaxConcurrent sessions are already uploading, the session stays queued
+// and the scheduler starts it, in priority order, as soon as a slot frees up.
+// While the manager or the session's client, owner or group is paused, the
+// session stays queued until the pause is lifted. Starting a paused session
+// resumes it.
 func (um *UploadManager) StartUpload(sessionID string) error {
 	um.mu.Lock()
 	defer um.mu.Unlock()
 
+	if um.closed {
+		return ErrManagerShutdown
+	}
 	session, exists := um.sessions[sessionID]
 	if !exists {
 		return fmt.Errorf("session %s not found", sessionID)
 	}
 
-	if session.Status != StatusQueued && session.Status != StatusPaused {
+	switch {
+	case session.Status == StatusPaused && session.running:
+		um.resumeLocked(session)
+		return nil
+	case session.Status != StatusQueued && session.Status != StatusPaused:
 		return fmt.Errorf("session %s cannot be started (current status: %s)", sessionID, session.Status)
 	}
 
-	session.Status = StatusRunning
-	session.UpdatedAt = time.Now()
+	um.requestStartLocked(session)
+	return nil
+}
+
+// requestStartLocked queues a session that has no running upload to be
+// started by the scheduler. um.mu must be held.
+func (um *UploadManager) requestStartLocked(session *UploadSession) {
+	um.queueStartLocked(session)
+	um.scheduleLocked()
+}
 
-	// Start upload in goroutine
-	go func(sess *UploadSession) {
-		err := sess.Client.UploadFileWithConfig(sess.LocalPath, sess.RemotePath, sess.Config)
+// queueStartLocked marks a session without a running upload as waiting to
+// be started, without running the scheduler. um.mu must be held.
+func (um *UploadManager) queueStartLocked(session *UploadSession) {
+	session.startRequested = true
+	session.stopRetryLocked()
+	um.setStatusLocked(session, StatusQueued)
+}
+
+// scheduleLocked applies session schedules and owner quotas, then starts
+// waiting sessions, highest priority first and FIFO within a priority (shared
+// between owners per ManagerConfig.Fairness), while slots are free.
+// um.mu must be held.
+func (um *UploadManager) scheduleLocked() {
+	if um.closed {
+		return
+	}
+	now := time.Now()
+	um.applySchedulesLocked(now)
+	um.applyQuotasLocked(now)
+	defer um.armScheduleTimerLocked(now)
+
+	var waiting []*UploadSession
+	for _, sess := range um.sessions {
+		if sess.Status == StatusQueued && !sess.running && (sess.startRequested || um.config.AutoStart) &&
+			!now.Before(sess.NextRetry) && sess.Schedule.allows(now) && um.dependenciesMetLocked(sess) &&
+			!um.gatedLocked(sess) {
+			waiting = append(waiting, sess)
+		}
+	}
+	sortQueue(waiting)
+
+	for um.config.MaxConcurrent <= 0 || um.active < um.config.MaxConcurrent {
+		sess := um.nextWaitingLocked(&waiting, now)
+		if sess == nil {
+			return
+		}
+		um.launchLocked(sess)
+	}
+}
+
+// launchLocked starts the upload goroutine for a session. um.mu must be held.
+func (um *UploadManager) launchLocked(sess *UploadSession) {
+	sess.startRequested = false
+	sess.running = true
+	sess.detached = false
+	sess.checkpointStop = false
+	sess.PausedBySchedule = false
+	sess.PausedByQuota = false
+	sess.stopRetryLocked()
+	um.active++
+	um.uploads.Add(1)
+	owner := um.ownerLocked(sess.Owner)
+	owner.active++
+	resumeFrom := um.resumePoint(sess)
+	sess.Stats.begin(resumeFrom, time.Now())
+	um.setStatusLocked(sess, StatusRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sess.cancel = cancel
+
+	cfg := *sess.Config
+	um.observeCallbacks(sess, &cfg)
+	cfg.ResumeFromCheckpoint = resumeFrom
+	cfg.throttle = owner.limiter
+	userCheckpoint := cfg.CheckpointFunc
+	cfg.CheckpointFunc = func(cp Checkpoint) {
 		um.mu.Lock()
-		if err != nil {
-			sess.Status = StatusFailed
-		} else {
-			sess.Status = StatusCompleted
+		sess.Checkpoint = &cp
+		um.persistLocked(sess)
+		if sess.checkpointStop && sess.cancel != nil {
+			// Paused because its window closed, quota ran out or the manager
+			// is shutting down: stop and release the slot
+			sess.cancel()
 		}
-		sess.UpdatedAt = time.Now()
 		um.mu.Unlock()
-	}(session)
+		if userCheckpoint != nil {
+			userCheckpoint(cp)
+		}
+	}
+
+	go func() {
+		client := sess.Client.withConfig(&cfg)
+		skipped, err := client.uploadFileCore(ctx, sess.LocalPath, sess.RemotePath)
+		info := &CompletionInfo{
+			SessionID:  sess.ID,
+			LocalPath:  sess.LocalPath,
+			RemotePath: client.sanitizeRemotePath(sess.RemotePath),
+			Skipped:    skipped,
+		}
+		var hookErr error
+		if err == nil {
+			hookErr = runHooks(ctx, client, sess.hooks, info)
+		}
+		cancel()
+
+		defer um.uploads.Done()
+		um.mu.Lock()
+		defer um.mu.Unlock()
+		sess.running = false
+		sess.cancel = nil
+		checkpointStop := sess.checkpointStop
+		sess.checkpointStop = false
+		um.active--
+		owner.active--
+		switch {
+		case checkpointStop && err != nil && sess.Controller.State() != StateCancelled:
+			// Stopped with a checkpoint because its window closed, quota
+			// ran out or the manager shut down; resumed from the checkpoint
+			// by the scheduler later
+			sess.detached = true
+			switch sess.Status {
+			case StatusRunning:
+				// Resumed while stopping, or stopped by Shutdown
+				sess.Controller.Resume()
+				um.queueStartLocked(sess)
+			case StatusQueued:
+				// Resumed while stopping for a pause that still holds it
+				sess.Controller.Resume()
+			}
+		case sess.Status == StatusCancelled:
+			// Cancelled by CancelUpload: the status is already final
+			um.finishLocked(sess)
+		case sess.Controller.State() == StateCancelled:
+			// Cancelled directly through the controller
+			sess.Err = ErrUploadCancelled
+			sess.Checkpoint = nil
+			um.setStatusLocked(sess, StatusCancelled)
+		case err != nil:
+			sess.Stats.LastError = err
+			if !um.retryLocked(sess, err) {
+				um.failLocked(sess, err)
+			}
+		case hookErr != nil:
+			// The file is uploaded: retrying the upload would not help
+			sess.Checkpoint = nil
+			sess.Stats.LastError = hookErr
+			um.failLocked(sess, hookErr)
+		default:
+			sess.Checkpoint = nil
+			sess.FinalPath = info.RemotePath
+			sess.Stats.completed()
+			um.setStatusLocked(sess, StatusCompleted)
+		}
+		um.scheduleLocked()
+	}()
+}
+
+// failLocked marks a session as failed with err and publishes
+// ManagerEventError. um.mu must be held.
+func (um *UploadManager) failLocked(sess *UploadSession, err error) {
+	sess.Err = err
+	um.setStatusLocked(sess, StatusFailed)
+	um.events.publish(ManagerEvent{
+		Type:      ManagerEventError,
+		SessionID: sess.ID,
+		GroupID:   sess.GroupID,
+		Client:    sess.Client,
+		Status:    StatusFailed,
+		Err:       err,
+	})
+}
+
+// resumePoint returns the session's checkpoint if the upload can continue
+// from it: the local file version and the chunk size must be unchanged. A
+// checkpoint of a changed file is dropped. um.mu must be held.
+func (um *UploadManager) resumePoint(sess *UploadSession) *Checkpoint {
+	cp := sess.Checkpoint
+	if cp == nil {
+		return nil
+	}
+	if info, err := os.Stat(sess.LocalPath); err != nil || !cp.matchesFile(info, sess.Config.ChunkSize) {
+		sess.Checkpoint = nil
+		return nil
+	}
+	return cp
+}
+
+// persistLocked saves the session to the store, if any. um.mu must be held.
+func (um *UploadManager) persistLocked(sess *UploadSession) {
+	if um.config.Store == nil {
+		return
+	}
+	if err := um.config.Store.Save(sessionRecordOf(sess)); err != nil && sess.Config.Verbose {
+		log.Printf("session store: %v", err)
+	}
+}
+
+// sessionRecordOf converts a session to its persisted form.
+func sessionRecordOf(sess *UploadSession) SessionRecord {
+	return SessionRecord{
+		ID:             sess.ID,
+		LocalPath:      sess.LocalPath,
+		RemotePath:     sess.RemotePath,
+		BaseURL:        sess.Client.baseURL,
+		Username:       sess.Client.username,
+		Status:         sess.Status,
+		Priority:       sess.Priority,
+		GroupID:        sess.GroupID,
+		Owner:          sess.Owner,
+		DependsOn:      sess.DependsOn,
+		FinalPath:      sess.FinalPath,
+		StartRequested: sess.startRequested,
+		Config:         sessionConfigOf(sess.Config),
+		Checkpoint:     sess.Checkpoint,
+		Error:          errorString(sess.Err),
+		Attempts:       sess.Stats.Attempts,
+		Failures:       sess.Stats.Failures,
+		CreatedAt:      sess.CreatedAt,
+		UpdatedAt:      sess.UpdatedAt,
+	}
+}
+
+// RestoreSessions reloads the sessions saved in ManagerConfig.Store, for
+// example after a restart. resolve supplies the client for each session; the
+// session's config is the client's current config with the persisted fields
+// applied.
+//
+// Sessions that were running when the process stopped are queued again and
+// started by the scheduler, continuing from their latest checkpoint when the
+// local file is unchanged. Paused sessions stay paused until resumed, queued
+// and finished sessions keep their status. Sessions already known to the
+// manager are left alone. Records whose client cannot be resolved are skipped
+// and reported in the returned error.
+//
+// Example:
+//
+//	restored, err := manager.RestoreSessions(func(rec godav.SessionRecord) (*godav.Client, error) {
+//		return clientsByUser[rec.Username], nil
+//	})
+func (um *UploadManager) RestoreSessions(resolve ClientResolver) ([]*UploadSession, error) {
+	if um.config.Store == nil {
+		return nil, fmt.Errorf("restore sessions: no session store configured")
+	}
+	records, err := um.config.Store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("restore sessions: %w", err)
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	var restored []*UploadSession
+	var errs []error
+	for _, rec := range records {
+		if _, exists := um.sessions[rec.ID]; exists {
+			continue
+		}
+		client, err := resolve(rec)
+		if err == nil && client == nil {
+			err = fmt.Errorf("no client")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restore session %s: %w", rec.ID, err))
+			continue
+		}
+
+		if client.config == nil {
+			client.config = DefaultConfig()
+		}
+		sessCfg := *client.config
+		rec.Config.apply(&sessCfg)
+		sessCfg.Controller = NewUploadController(rec.ID, um)
+
+		um.seq++
+		session := &UploadSession{
+			ID:             rec.ID,
+			LocalPath:      rec.LocalPath,
+			RemotePath:     rec.RemotePath,
+			Client:         client,
+			Controller:     sessCfg.Controller,
+			Config:         &sessCfg,
+			Status:         rec.Status,
+			Priority:       rec.Priority,
+			GroupID:        rec.GroupID,
+			Owner:          rec.Owner,
+			DependsOn:      rec.DependsOn,
+			FinalPath:      rec.FinalPath,
+			Schedule:       um.config.Schedule,
+			Checkpoint:     rec.Checkpoint,
+			CreatedAt:      rec.CreatedAt,
+			UpdatedAt:      rec.UpdatedAt,
+			seq:            um.seq,
+			startRequested: rec.StartRequested,
+			detached:       true,
+			done:           make(chan struct{}),
+		}
+		switch session.Status {
+		case StatusRunning:
+			// Interrupted mid-upload: resume automatically
+			session.Status = StatusQueued
+			session.startRequested = true
+		case StatusCancelled:
+			session.Err = ErrUploadCancelled
+		case StatusFailed:
+			if rec.Error != "" {
+				session.Err = errors.New(rec.Error)
+			}
+		}
+		session.Stats.initStats(rec.LocalPath)
+		session.Stats.LastError = session.Err
+		session.Stats.Attempts = rec.Attempts
+		session.Stats.Failures = rec.Failures
+		if cp := rec.Checkpoint; cp != nil {
+			session.Stats.BytesSent, session.Stats.ChunksDone, session.Stats.ChunksTotal = cp.BytesUploaded, cp.ChunksUploaded, cp.TotalChunks
+			session.Stats.percent()
+		}
+		if session.Status == StatusCompleted {
+			session.Stats.completed()
+		}
+		session.finishLocked()
+		um.sessions[rec.ID] = session
+		um.persistLocked(session)
+		if rec.GroupID != "" {
+			um.restoreGroupMemberLocked(rec.GroupID, rec.ID, rec.CreatedAt)
+		}
+
+		sessionCopy := *session
+		restored = append(restored, &sessionCopy)
+	}
+
+	for _, group := range um.groups {
+		um.checkGroupLocked(group)
+	}
+	for _, sess := range um.sessions {
+		if sess.Status == StatusFailed || sess.Status == StatusCancelled {
+			um.cancelDependentsLocked(sess)
+		}
+	}
+	um.scheduleLocked()
+	return restored, errors.Join(errs...)
+}
+
+// sortQueue orders sessions by descending priority, then by queue position.
+func sortQueue(sessions []*UploadSession) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Priority != sessions[j].Priority {
+			return sessions[i].Priority > sessions[j].Priority
+		}
+		return sessions[i].seq < sessions[j].seq
+	})
+}
+
+// QueuedSessions returns copies of the queued sessions in the order the
+// scheduler will start them.
+func (um *UploadManager) QueuedSessions() []*UploadSession {
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+
+	var queued []*UploadSession
+	for _, sess := range um.sessions {
+		if sess.Status == StatusQueued {
+			queued = append(queued, sess)
+		}
+	}
+	sortQueue(queued)
+	for i, sess := range queued {
+		sessionCopy := *sess
+		queued[i] = &sessionCopy
+	}
+	return queued
+}
+
+// SetPriority changes the priority of a queued session. Sessions that are
+// already running are not affected.
+func (um *UploadManager) SetPriority(sessionID string, priority int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	session, err := um.queuedSessionLocked(sessionID)
+	if err != nil {
+		return err
+	}
+	session.Priority = priority
+	session.UpdatedAt = time.Now()
+	um.persistLocked(session)
+	um.scheduleLocked()
+	return nil
+}
+
+// MoveToFront moves a queued session ahead of all other queued sessions of
+// the same priority.
+func (um *UploadManager) MoveToFront(sessionID string) error {
+	return um.requeue(sessionID, true)
+}
+
+// MoveToBack moves a queued session behind all other queued sessions of the
+// same priority.
+func (um *UploadManager) MoveToBack(sessionID string) error {
+	return um.requeue(sessionID, false)
+}
 
+func (um *UploadManager) requeue(sessionID string, front bool) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	session, err := um.queuedSessionLocked(sessionID)
+	if err != nil {
+		return err
+	}
+	if front {
+		first := session.seq
+		for _, sess := range um.sessions {
+			if sess.Status == StatusQueued && sess.seq < first {
+				first = sess.seq
+			}
+		}
+		if first < session.seq {
+			session.seq = first - 1
+		}
+	} else {
+		um.seq++
+		session.seq = um.seq
+	}
+	session.UpdatedAt = time.Now()
+	um.scheduleLocked()
 	return nil
 }
 
+// unfinishedSessionLocked returns the session if it has not finished.
+// um.mu must be held.
+func (um *UploadManager) unfinishedSessionLocked(sessionID string) (*UploadSession, error) {
+	session, exists := um.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	if isTerminal(session.Status) {
+		return nil, fmt.Errorf("session %s has finished (current status: %s)", sessionID, session.Status)
+	}
+	return session, nil
+}
+
+// queuedSessionLocked returns the session if it is queued. um.mu must be held.
+func (um *UploadManager) queuedSessionLocked(sessionID string) (*UploadSession, error) {
+	session, exists := um.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	if session.Status != StatusQueued {
+		return nil, fmt.Errorf("session %s is not queued (current status: %s)", sessionID, session.Status)
+	}
+	return session, nil
+}
+
+// SetMaxConcurrent changes the concurrency limit at runtime (0 = unlimited).
+// Raising it starts waiting sessions immediately; lowering it lets running
+// sessions finish.
+func (um *UploadManager) SetMaxConcurrent(n int) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	um.config.MaxConcurrent = n
+	um.scheduleLocked()
+}
+
 // PauseUpload pauses a specific upload session
 func (um *UploadManager) PauseUpload(sessionID string) error {
 	um.mu.Lock()
@@ -160,8 +862,7 @@ func (um *UploadManager) PauseUpload(sessionID string) error {
 	}
 
 	session.Controller.Pause()
-	session.Status = StatusPaused
-	session.UpdatedAt = time.Now()
+	um.setStatusLocked(session, StatusPaused)
 	return nil
 }
 
@@ -179,13 +880,114 @@ func (um *UploadManager) ResumeUpload(sessionID string) error {
 		return fmt.Errorf("session %s is not paused", sessionID)
 	}
 
-	session.Controller.Resume()
-	session.Status = StatusRunning
-	session.UpdatedAt = time.Now()
+	um.resumeLocked(session)
 	return nil
 }
 
-// PauseAllUploads pauses all running uploads
+// resumeLocked resumes a paused session. A session without a running upload
+// (e.g. one restored by RestoreSessions) is queued to start from its
+// checkpoint, as is one still stopping for a pause that holds it.
+// um.mu must be held.
+func (um *UploadManager) resumeLocked(session *UploadSession) {
+	session.PausedBySchedule = false
+	session.PausedByQuota = false
+	switch {
+	case session.detached:
+		session.Controller.Resume()
+		um.requestStartLocked(session)
+	case session.checkpointStop && um.gatedLocked(session):
+		// Started by the scheduler once stopped and no longer held
+		session.startRequested = true
+		um.setStatusLocked(session, StatusQueued)
+	default:
+		session.Controller.Resume()
+		um.setStatusLocked(session, StatusRunning)
+	}
+}
+
+// CancelUpload cancels a queued, running or paused session. The session moves
+// to StatusCancelled with Err set to ErrUploadCancelled, and the chunks already
+// uploaded to the server are removed. The chunk request in flight is aborted
+// and a paused upload stops waiting; Done is closed once it has exited.
+//
+// Example:
+//
+//	if err := manager.CancelUpload(session.ID); err != nil {
+//		log.Printf("cancel: %v", err)
+//	}
+//	_ = manager.Wait(ctx, session.ID) // returns godav.ErrUploadCancelled
+func (um *UploadManager) CancelUpload(sessionID string) error {
+	um.mu.Lock()
+	session, exists := um.sessions[sessionID]
+	if !exists {
+		um.mu.Unlock()
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+	if isTerminal(session.Status) {
+		um.mu.Unlock()
+		return fmt.Errorf("session %s cannot be cancelled (current status: %s)", sessionID, session.Status)
+	}
+
+	session.Controller.Cancel()
+	if session.cancel != nil {
+		// Wakes a paused upload; the upload removes its chunks on exit
+		session.cancel()
+	}
+	var cp *Checkpoint
+	if !session.running {
+		cp = session.Checkpoint
+	}
+	session.Checkpoint = nil
+	session.startRequested = false
+	session.stopRetryLocked()
+	session.Err = ErrUploadCancelled
+	um.setStatusLocked(session, StatusCancelled)
+	client := session.Client
+	um.mu.Unlock()
+
+	// A session without a running upload may still have chunks on the
+	// server from before it was paused or restored
+	if cp != nil && cp.UploadID != "" {
+		if err := client.RemoveAll(client.pathJoinMany("uploads", client.username, cp.UploadID)); err != nil && !gowebdav.IsErrNotFound(err) {
+			return fmt.Errorf("cancel %s: remove uploaded chunks: %w", sessionID, err)
+		}
+	}
+	return nil
+}
+
+// Wait blocks until the session has finished or ctx is done. It returns nil
+// if the upload completed, the failure cause if it failed, ErrUploadCancelled
+// if it was cancelled, and ctx.Err() if ctx ends first.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+//	defer cancel()
+//	if err := manager.Wait(ctx, session.ID); err != nil {
+//		log.Printf("upload %s: %v", session.ID, err)
+//	}
+func (um *UploadManager) Wait(ctx context.Context, sessionID string) error {
+	um.mu.RLock()
+	session, exists := um.sessions[sessionID]
+	um.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("session %s not found", sessionID)
+	}
+
+	select {
+	case <-session.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	um.mu.RLock()
+	defer um.mu.RUnlock()
+	return session.Err
+}
+
+// PauseAllUploads pauses the manager: running uploads abort their chunk in
+// flight and stop with a checkpoint, and no session is started until
+// ResumeAllUploads. See also PauseClient, PauseOwner and PauseGroup.
 func (um *UploadManager) PauseAllUploads() {
 	um.mu.Lock()
 	defer um.mu.Unlock()
@@ -194,16 +996,12 @@ func (um *UploadManager) PauseAllUploads() {
 	um.globalCtrl.globalPaused = true
 	um.globalCtrl.mu.Unlock()
 
-	for _, session := range um.sessions {
-		if session.Status == StatusRunning {
-			session.Controller.Pause()
-			session.Status = StatusPaused
-			session.UpdatedAt = time.Now()
-		}
-	}
+	um.pauseMatchingLocked(func(*UploadSession) bool { return true })
 }
 
-// ResumeAllUploads resumes all paused uploads
+// ResumeAllUploads lifts PauseAllUploads, resumes all paused uploads and
+// starts the queued sessions held meanwhile. Sessions of a paused client,
+// owner or group stay queued until that pause is lifted.
 func (um *UploadManager) ResumeAllUploads() {
 	um.mu.Lock()
 	defer um.mu.Unlock()
@@ -212,13 +1010,7 @@ func (um *UploadManager) ResumeAllUploads() {
 	um.globalCtrl.globalPaused = false
 	um.globalCtrl.mu.Unlock()
 
-	for _, session := range um.sessions {
-		if session.Status == StatusPaused {
-			session.Controller.Resume()
-			session.Status = StatusRunning
-			session.UpdatedAt = time.Now()
-		}
-	}
+	um.resumeMatchingLocked(func(*UploadSession) bool { return true })
 }
 
 // GetUploadSessions returns all upload sessions
@@ -251,7 +1043,8 @@ func (um *UploadManager) GetUploadSession(sessionID string) (*UploadSession, err
 	return &sessionCopy, nil
 }
 
-// RemoveUploadSession removes a completed or failed upload session
+// RemoveUploadSession removes a session that is not running. See also
+// RemoveSessions and ManagerConfig.Retention.
 func (um *UploadManager) RemoveUploadSession(sessionID string) error {
 	um.mu.Lock()
 	defer um.mu.Unlock()
@@ -265,8 +1058,7 @@ func (um *UploadManager) RemoveUploadSession(sessionID string) error {
 		return fmt.Errorf("cannot remove running session %s", sessionID)
 	}
 
-	delete(um.sessions, sessionID)
-	return nil
+	return um.removeSessionLocked(session)
 }
 
 // IsGloballyPaused returns whether all uploads are globally paused
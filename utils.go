@@ -13,10 +13,17 @@
 package godav
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
+
+	gowebdav "github.com/studio-b12/gowebdav"
 )
 
 // Helper methods
@@ -24,6 +31,12 @@ import (
 // sanitizeRemotePath cleans a user-provided remote path and prevents traversal.
 // It rejects any path containing ".." segments after cleaning.
 func (c *Client) sanitizeRemotePath(p string) string {
+	return cleanRemotePath(p)
+}
+
+// cleanRemotePath implements sanitizeRemotePath for code without a client,
+// such as checkpoint stores.
+func cleanRemotePath(p string) string {
 	p = strings.TrimSpace(p)
 	p = strings.TrimPrefix(p, "/")
 	// If user passed a full files/.. path, strip the leading files/<anything>/ prefix.
@@ -121,6 +134,104 @@ func (c *Client) emitEvent(event UploadEvent, filename, path, message string, er
 	}
 }
 
+// davRequest sends a single request relative to the WebDAV root, for cases
+// gowebdav cannot express: custom PROPFIND bodies, per-request headers or
+// context cancellation. The caller must close the response body.
+func (c *Client) davRequest(ctx context.Context, method, p string, body io.Reader, hdr http.Header) (*http.Response, error) {
+	uri := c.baseURL + gowebdav.PathEscape(strings.TrimPrefix(p, "/"))
+	return c.send(ctx, method, uri, p, body, hdr)
+}
+
+// send sends a request the way the embedded gowebdav client does: with the
+// headers added by SetHeader, authorized by the client's Authorizer (which
+// may take another round trip) and passed through the interceptors. hdr
+// overrides headers added by SetHeader; p is the path passed to the
+// Authorizer. The caller must close the response body.
+func (c *Client) send(ctx context.Context, method, uri, p string, body io.Reader, hdr http.Header) (*http.Response, error) {
+	// The authenticator wraps the body for replays, hiding its length
+	length := int64(-1)
+	if l, ok := body.(interface{ Len() int }); ok {
+		length = int64(l.Len())
+	}
+	auth, body := c.auth.NewAuthenticator(body)
+	defer auth.Close()
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, method, uri, body)
+		if err != nil {
+			return nil, err
+		}
+		if length >= 0 {
+			req.ContentLength = length
+		}
+		c.hooks.mu.RLock()
+		for k, vals := range c.hooks.headers {
+			for _, v := range vals {
+				req.Header.Add(k, v)
+			}
+		}
+		c.hooks.mu.RUnlock()
+		for k, vals := range hdr {
+			req.Header[k] = vals
+		}
+		if err := auth.Authorize(c.http, req, p); err != nil {
+			return nil, err
+		}
+		c.intercept(method, req)
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		redo, err := auth.Verify(c.http, resp, p)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if !redo {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so a crash never leaves a partially written file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
 // validateConfig validates and sanitizes the configuration
 func (c *Client) validateConfig() *Config {
 	if c.config == nil {